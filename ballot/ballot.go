@@ -0,0 +1,155 @@
+// Package ballot tallies one-hot votes homomorphically. A vote is a
+// transciphered bfv ciphertext with a 1 in the slot of its candidate and 0 in
+// the others, the tally is the running sum of the votes and only the final
+// tally is ever decrypted.
+package ballot
+
+import (
+	"errors"
+	"fmt"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/multiparty"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Tally is an encrypted running tally, slot i of the first row of Ciphertext
+// counts the votes of candidate i. Ciphertext is nil until the first vote.
+type Tally struct {
+	Candidates uint64
+	Ciphertext *rlwe.Ciphertext
+}
+
+// NewTally returns an empty tally for candidates candidates
+func NewTally(params bfv.Parameters, candidates uint64) (*Tally, error) {
+	if err := validateCandidates(params, candidates); err != nil {
+		return nil, err
+	}
+
+	return &Tally{Candidates: candidates}, nil
+}
+
+// ResumeTally picks up a running tally from its ciphertext
+func ResumeTally(params bfv.Parameters, candidates uint64, ct *rlwe.Ciphertext) (*Tally, error) {
+	if err := validateCandidates(params, candidates); err != nil {
+		return nil, err
+	}
+	if ct == nil {
+		return nil, errors.New("missing tally ciphertext")
+	}
+
+	return &Tally{candidates, ct}, nil
+}
+
+func validateCandidates(params bfv.Parameters, candidates uint64) error {
+	if candidates == 0 {
+		return errors.New("a ballot needs at least one candidate")
+	}
+	if max := uint64(params.N() / 2); candidates > max {
+		return fmt.Errorf("a ballot holds at most %d candidates, got %d", max, candidates)
+	}
+
+	return nil
+}
+
+// Add adds a transciphered vote to the tally, it must hold Candidates
+// elements in the first slots of its first row and zeroes elsewhere (as
+// Transcipher packs them)
+func (t *Tally) Add(vote *rlwe.Ciphertext, evaluator bfv.Evaluator) {
+	if t.Ciphertext == nil {
+		t.Ciphertext = vote.CopyNew()
+		return
+	}
+	evaluator.Add(t.Ciphertext, vote, t.Ciphertext)
+}
+
+// AddValid adds vote masked by its validity flag (see MaskVote), an invalid
+// vote leaves the tally as it was. The evaluator also needs the keys of
+// ValidityGaloisElements, and the tally can't have more than N/4 candidates
+// (see ValidityFlag).
+func (t *Tally) AddValid(vote *rlwe.Ciphertext, encoder bfv.Encoder, evaluator bfv.Evaluator,
+	params bfv.Parameters) error {
+
+	masked, err := MaskVote(vote, t.Candidates, encoder, evaluator, params)
+	if err != nil {
+		return err
+	}
+	t.Add(masked, evaluator)
+
+	return nil
+}
+
+// AddPasta transciphers a vote pasta encrypted with nonce (see
+// pasta.EncryptWithNonce) with the bfv encrypted pasta key and adds it to the
+// tally. Every vote under a pasta key needs its own nonce, two votes sharing
+// one leak their difference, the tally can't tell so keep track of the
+// nonces used. The evaluator only needs the keys of GenTranscipherKeys for
+// messages of Candidates elements.
+func (t *Tally) AddPasta(vote []uint64, nonce uint64, pastaKeyCt *rlwe.Ciphertext, encoder bfv.Encoder,
+	evaluator bfv.Evaluator, scratch *hhegobfv.Scratch) error {
+
+	if uint64(len(vote)) != t.Candidates {
+		return fmt.Errorf("expected a vote of %d elements, got %d", t.Candidates, len(vote))
+	}
+
+	res, err := hhegobfv.TranscipherWithNonce(vote, nonce, pastaKeyCt, PastaParams, pasta.DefaultSecLevel,
+		encoder, evaluator, scratch, hhegobfv.TranscipherOptions{})
+	if err != nil {
+		return err
+	}
+	t.Add(res[0].Ciphertext, evaluator)
+
+	return nil
+}
+
+// GenKeys generates from sk the evaluation keys to transcipher votes for
+// candidates candidates and, if validate is set, to check them with AddValid
+func GenKeys(params bfv.Parameters, sk *rlwe.SecretKey, candidates uint64, validate bool) rlwe.EvaluationKeySet {
+	evks := hhegobfv.GenTranscipherKeys(params, sk, candidates, hhegobfv.TranscipherOptions{})
+	if validate {
+		kgen := rlwe.NewKeyGenerator(params.Parameters)
+		for _, galEl := range ValidityGaloisElements(params.Parameters, candidates) {
+			if _, ok := evks.GaloisKeys[galEl]; !ok {
+				evks.GaloisKeys[galEl] = kgen.GenGaloisKeyNew(galEl, sk)
+			}
+		}
+	}
+
+	return evks
+}
+
+// Result decrypts the tally, it returns the votes of each candidate
+func (t *Tally) Result(decryptor rlwe.Decryptor, encoder bfv.Encoder) []uint64 {
+	if t.Ciphertext == nil {
+		return make([]uint64, t.Candidates)
+	}
+
+	return hhegobfv.DecryptPacked(hhegobfv.Unpacked(t.Ciphertext, t.Candidates), decryptor, encoder)
+}
+
+// ThresholdResult combines the decryption shares the active parties of s
+// generated over the tally (see multiparty.Party.DecryptionShare), it
+// returns the votes of each candidate
+func (t *Tally) ThresholdResult(s *multiparty.Session, shares []*drlwe.CKSShare) ([]uint64, error) {
+	if t.Ciphertext == nil {
+		return make([]uint64, t.Candidates), nil
+	}
+
+	slots, err := s.Decrypt(t.Ciphertext, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	return slots[:t.Candidates], nil
+}
+
+// PastaParams are the pasta parameters votes are encrypted with
+var PastaParams = pasta.Params{
+	SecretKeySize:  pasta.SecretKeySize,
+	PlaintextSize:  pasta.PlaintextSize,
+	CiphertextSize: pasta.CiphertextSize,
+	Rounds:         pasta.Rounds,
+}
@@ -0,0 +1,96 @@
+package ballot
+
+import (
+	"testing"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+)
+
+func TestTally(t *testing.T) {
+	candidates := uint64(4)
+	client, server, err := keystore.Generate(1<<15, 65537, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := server.Params()
+	encoder := bfv.NewEncoder(params)
+	evaluator := bfv.NewEvaluator(params, server.EvaluationKeys)
+	pastaCipher := pasta.NewPasta(client.PastaKey, server.Modulus, PastaParams)
+
+	if _, err := NewTally(params, 0); err == nil {
+		t.Errorf("expected an error without candidates")
+	}
+	tally, err := NewTally(params, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every vote comes with its own nonce
+	votes := [][]uint64{{0, 1, 0, 0}, {1, 0, 0, 0}, {0, 1, 0, 0}}
+	scratch := hhegobfv.NewScratch(params)
+	for v, vote := range votes {
+		nonce := uint64(1000 + v)
+		if err := tally.AddPasta(pastaCipher.EncryptWithNonce(vote, nonce), nonce, server.PastaKeyCt, encoder,
+			evaluator, scratch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tally.AddPasta([]uint64{1, 2}, 0, server.PastaKeyCt, encoder, evaluator, scratch); err == nil {
+		t.Errorf("expected an error for a vote of the wrong length")
+	}
+
+	// the tally survives a round trip through its ciphertext alone
+	resumed, err := ResumeTally(params, candidates, tally.Ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := resumed.Result(bfv.NewDecryptor(params, client.SecretKey), encoder)
+	if !util.EqualSlices(result, []uint64{1, 2, 0, 0}) {
+		t.Errorf("wrong tally %v", result)
+	}
+}
+
+func TestTallyNonces(t *testing.T) {
+	candidates := uint64(4)
+	client, server, err := keystore.Generate(1<<15, 65537, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := server.Params()
+	encoder := bfv.NewEncoder(params)
+	evaluator := bfv.NewEvaluator(params, server.EvaluationKeys)
+	decryptor := bfv.NewDecryptor(params, client.SecretKey)
+	pastaCipher := pasta.NewPasta(client.PastaKey, server.Modulus, PastaParams)
+	scratch := hhegobfv.NewScratch(params)
+
+	// the same vote under two nonces gives two different pasta ciphertexts
+	vote := []uint64{0, 0, 1, 0}
+	first, second := pastaCipher.EncryptWithNonce(vote, 1), pastaCipher.EncryptWithNonce(vote, 2)
+	if util.EqualSlices(first, second) {
+		t.Fatalf("votes under different nonces share their ciphertext")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		nonce  uint64
+		counts bool
+	}{
+		{"own nonce", 2, true},
+		{"other nonce", 1, false},
+	} {
+		tally, err := NewTally(params, candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tally.AddPasta(second, tc.nonce, server.PastaKeyCt, encoder, evaluator, scratch); err != nil {
+			t.Fatal(err)
+		}
+		if got := util.EqualSlices(tally.Result(decryptor, encoder), vote); got != tc.counts {
+			t.Errorf("%s: vote counted %v, expected %v", tc.name, got, tc.counts)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package ballot
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ValidityModulus is the pasta and bfv plaintext modulus of ValidityParams,
+// the smallest prime = 1 mod 2^17 so every slot of a 2^16 ring is usable
+// (65537 only allows up to 2^15)
+const ValidityModulus = 786433
+
+// ValidityParams are 128 bit secure 2^16 parameters where a transciphered
+// vote still has the levels ValidityFlag and MaskVote take, about 430 bits
+// of noise for the transcipher plus ~35 per level. Votes to be checked must
+// be pasta encrypted with ValidityModulus.
+//
+// With the 2^15 parameters JNI uses (T = 65537) a transcipher leaves about
+// 9 levels, short of the log2(T) + 3 the check needs, so there it only fits
+// freshly encrypted votes. No shallower circuit is sound: a 0/1 flag of a
+// value an attacker picks freely in Z_T is a polynomial of degree T-1.
+var ValidityParams = bfv.ParametersLiteral{
+	LogN: 16,
+	LogQ: []int{60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60, 60},
+	LogP: []int{61, 61, 61, 61, 61, 61},
+	T:    ValidityModulus,
+}
+
+// ValidityFlag returns an encrypted flag, 1 in each of the first candidates
+// slots if those slots of vote are one-hot and 0 otherwise, without
+// revealing which one it is. The rest of vote is ignored.
+//
+// A vote is one-hot if every slot v satisfies v*(v-1) = 0 and the slots add
+// up to 1. Both conditions are folded into a random linear combination
+// E = sum(r_i*v_i*(v_i-1)) + r_0*(sum(v_i)-1), zero for every valid vote and
+// for an invalid one with probability 1/T, and the flag is 1 - E^(T-1)
+// (see hhegobfv.IsZero). It takes hhegobfv.IsZeroDepth(T) + 2 levels of
+// multiplications, see ValidityParams for transciphered votes.
+// The keys are given by ValidityGaloisElements. It checks up to N/4
+// candidates, half of what a tally holds: the reduction places a copy of the
+// window right after it, past N/4 the copy wraps onto the window itself.
+func ValidityFlag(vote *rlwe.Ciphertext, candidates uint64, encoder bfv.Encoder, evaluator bfv.Evaluator,
+	params bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	if err := validateValidityCandidates(params, candidates); err != nil {
+		return nil, err
+	}
+
+	t := params.T()
+	r, err := randomCoefficients(candidates+1, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// r_i*v_i^2 + (r_0-r_i)*v_i on each candidate slot, 0 elsewhere
+	linear := make([]uint64, candidates)
+	for i := range linear {
+		linear[i] = (r[0] + t - r[i+1]) % t
+	}
+	e := evaluator.MulRelinNew(vote, vote)
+	evaluator.Mul(e, encodeCoefficients(r[1:], encoder, params), e)            // ct x pt
+	evaluator.MulThenAdd(vote, encodeCoefficients(linear, encoder, params), e) // ct x pt
+
+	// E on each candidate slot: with a copy of the window right after it,
+	// the window sum starting at any of its slots covers it whole
+	window := evaluator.RotateColumnsNew(e, -int(candidates))
+	evaluator.Add(e, window, e)
+	sum := hhegobfv.SumSlots(e, candidates, evaluator, params)
+	evaluator.Sub(sum, r[0], sum)
+
+	return hhegobfv.IsZero(sum, evaluator, params), nil
+}
+
+func validateValidityCandidates(params bfv.Parameters, candidates uint64) error {
+	if err := validateCandidates(params, candidates); err != nil {
+		return err
+	}
+	if max := uint64(params.N() / 4); candidates > max {
+		return fmt.Errorf("a validity check covers at most %d candidates, got %d", max, candidates)
+	}
+
+	return nil
+}
+
+// MaskVote returns vote times its validity flag, an invalid vote turns into
+// an encryption of zero and adds nothing to the tally
+func MaskVote(vote *rlwe.Ciphertext, candidates uint64, encoder bfv.Encoder, evaluator bfv.Evaluator,
+	params bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	flag, err := ValidityFlag(vote, candidates, encoder, evaluator, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluator.MulRelinNew(vote, flag), nil
+}
+
+// ValidityGaloisElements returns the sorted set of galois elements
+// ValidityFlag uses for candidates candidates, on top of them it needs the
+// relinearization key
+func ValidityGaloisElements(params rlwe.Parameters, candidates uint64) []uint64 {
+	galEls := hhegobfv.SumSlotsGaloisElements(params, candidates)
+	window := params.GaloisElementForColumnRotationBy(-int(candidates))
+	for _, galEl := range galEls {
+		if galEl == window {
+			return galEls
+		}
+	}
+	galEls = append(galEls, window)
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	return galEls
+}
+
+// randomCoefficients samples n uniform non zero values mod t
+func randomCoefficients(n, t uint64) ([]uint64, error) {
+	coeffs := make([]uint64, n)
+	max := new(big.Int).SetUint64(t - 1)
+	for i := range coeffs {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = v.Uint64() + 1
+	}
+
+	return coeffs, nil
+}
+
+func encodeCoefficients(coeffs []uint64, encoder bfv.Encoder, params bfv.Parameters) *rlwe.Plaintext {
+	values := make([]uint64, params.N())
+	copy(values, coeffs)
+	pt := bfv.NewPlaintext(params, params.MaxLevel())
+	encoder.Encode(values, pt)
+
+	return pt
+}
@@ -0,0 +1,208 @@
+package ballot
+
+import (
+	"fmt"
+	"testing"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// votes are freshly encrypted, a transcipher leaves the 2^15 parameters too
+// few levels for the check (see TestValidityTranscipher)
+func TestValidity(t *testing.T) {
+	candidates := uint64(4)
+	params := hhegobfv.GenerateBfvParams(65537, 1<<15)
+	sk, pk := rlwe.NewKeyGenerator(params.Parameters).GenKeyPairNew()
+	evks := GenKeys(params, sk, candidates, true)
+	encoder := bfv.NewEncoder(params)
+	encryptor := bfv.NewEncryptor(params, pk)
+	evaluator := bfv.NewEvaluator(params, &evks)
+	decryptor := bfv.NewDecryptor(params, sk)
+
+	tally, err := NewTally(params, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		vote  []uint64
+		valid bool
+	}{
+		{[]uint64{0, 1, 0, 0}, true},
+		{[]uint64{0, 5, 0, 0}, false},
+		{[]uint64{1, 1, 0, 0}, false},
+		{[]uint64{0, 0, 0, 0}, false},
+		{[]uint64{1, 65536, 1, 0}, false}, // adds up to 1
+		{[]uint64{0, 0, 0, 1}, true},
+	} {
+		t.Run(fmt.Sprint(tc.vote), func(t *testing.T) {
+			pt := bfv.NewPlaintext(params, params.MaxLevel())
+			encoder.Encode(tc.vote, pt)
+			vote := encryptor.EncryptNew(pt)
+
+			flag, err := ValidityFlag(vote, candidates, encoder, evaluator, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := uint64(0)
+			if tc.valid {
+				expected = 1
+			}
+			got := hhegobfv.DecryptPacked(hhegobfv.Unpacked(flag, candidates), decryptor, encoder)
+			if !util.EqualSlices(got, []uint64{expected, expected, expected, expected}) {
+				t.Errorf("expected a flag of %d, got %v", expected, got)
+			}
+
+			if err := tally.AddValid(vote, encoder, evaluator, params); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if result := tally.Result(decryptor, encoder); !util.EqualSlices(result, []uint64{0, 1, 0, 1}) {
+		t.Errorf("invalid votes changed the tally %v", result)
+	}
+}
+
+// 2^12 parameters (not secure, just deep enough for the check) keep the check
+// at N/4 candidates cheap
+func TestValidityCandidatesBound(t *testing.T) {
+	params, err := bfv.NewParametersFromLiteral(bfv.ParametersLiteral{
+		LogN: 12,
+		LogQ: []int{55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55, 55},
+		LogP: []int{61},
+		T:    40961, // the smallest prime = 1 mod 2^13
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound := uint64(params.N() / 4)
+	sk, pk := rlwe.NewKeyGenerator(params.Parameters).GenKeyPairNew()
+	evks := GenKeys(params, sk, bound, true)
+	encoder := bfv.NewEncoder(params)
+	encryptor := bfv.NewEncryptor(params, pk)
+	evaluator := bfv.NewEvaluator(params, &evks)
+	decryptor := bfv.NewDecryptor(params, sk)
+
+	encrypt := func(slots map[uint64]uint64) *rlwe.Ciphertext {
+		values := make([]uint64, bound)
+		for slot, v := range slots {
+			values[slot] = v
+		}
+		pt := bfv.NewPlaintext(params, params.MaxLevel())
+		encoder.Encode(values, pt)
+
+		return encryptor.EncryptNew(pt)
+	}
+
+	// at the bound the window copy ends right at the end of the row
+	for _, tc := range []struct {
+		name  string
+		vote  map[uint64]uint64
+		valid bool
+	}{
+		{"first candidate", map[uint64]uint64{0: 1}, true},
+		{"last candidate", map[uint64]uint64{bound - 1: 1}, true},
+		{"first and last candidates", map[uint64]uint64{0: 1, bound - 1: 1}, false},
+		{"no candidate", map[uint64]uint64{}, false},
+	} {
+		flag, err := ValidityFlag(encrypt(tc.vote), bound, encoder, evaluator, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := make([]uint64, bound)
+		if tc.valid {
+			for i := range expected {
+				expected[i] = 1
+			}
+		}
+		if got := hhegobfv.DecryptPacked(hhegobfv.Unpacked(flag, bound), decryptor, encoder); !util.EqualSlices(got,
+			expected) {
+			t.Errorf("%s: expected a flag of %d in every slot", tc.name, expected[0])
+		}
+	}
+
+	// just past it the copy would wrap onto the window
+	vote := encrypt(map[uint64]uint64{0: 1})
+	if _, err := ValidityFlag(vote, bound+1, encoder, evaluator, params); err == nil {
+		t.Errorf("expected an error for %d candidates", bound+1)
+	}
+	tally, err := NewTally(params, bound+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tally.AddValid(vote, encoder, evaluator, params); err == nil {
+		t.Errorf("expected an error adding a checked vote to a tally of %d candidates", bound+1)
+	}
+}
+
+// a transcipher takes over a minute and ~3 GB with ValidityParams
+func TestValidityTranscipher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 2^16 transciphers in short mode")
+	}
+
+	candidates := uint64(4)
+	params, err := bfv.NewParametersFromLiteral(ValidityParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, pk := rlwe.NewKeyGenerator(params.Parameters).GenKeyPairNew()
+	evks := GenKeys(params, sk, candidates, true)
+	encoder := bfv.NewEncoder(params)
+	evaluator := bfv.NewEvaluator(params, &evks)
+	decryptor := bfv.NewDecryptor(params, sk)
+
+	pastaKey, err := keystore.RandomPastaKey(ValidityModulus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pastaKeyCt := hhegobfv.EncryptPastaSecretKey(pastaKey, encoder, bfv.NewEncryptor(params, pk), params)
+	pastaCipher := pasta.NewPasta(pastaKey, ValidityModulus, PastaParams)
+	scratch := hhegobfv.NewScratch(params)
+
+	tally, err := NewTally(params, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		vote  []uint64
+		valid bool
+	}{
+		{[]uint64{0, 1, 0, 0}, true},
+		{[]uint64{1, ValidityModulus - 1, 1, 0}, false}, // adds up to 1
+	} {
+		t.Run(fmt.Sprint(tc.vote), func(t *testing.T) {
+			packed, err := hhegobfv.TranscipherScratch(pastaCipher.Encrypt(tc.vote), pastaKeyCt, PastaParams,
+				pasta.DefaultSecLevel, encoder, evaluator, scratch, hhegobfv.TranscipherOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			vote := packed[0].Ciphertext
+
+			flag, err := ValidityFlag(vote, candidates, encoder, evaluator, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := uint64(0)
+			if tc.valid {
+				expected = 1
+			}
+			got := hhegobfv.DecryptPacked(hhegobfv.Unpacked(flag, candidates), decryptor, encoder)
+			if !util.EqualSlices(got, []uint64{expected, expected, expected, expected}) {
+				t.Errorf("expected a flag of %d, got %v", expected, got)
+			}
+
+			masked := evaluator.MulRelinNew(vote, flag)
+			tally.Add(masked, evaluator)
+		})
+	}
+
+	if result := tally.Result(decryptor, encoder); !util.EqualSlices(result, []uint64{0, 1, 0, 0}) {
+		t.Errorf("invalid votes changed the tally %v", result)
+	}
+}
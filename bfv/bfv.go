@@ -1,10 +1,14 @@
 package bfv
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 
 	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
 	"github.com/tuneinsight/lattigo/v4/bfv"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
@@ -26,13 +30,16 @@ func NewBFV(bfvParams bfv.Parameters, encryptor rlwe.Encryptor, decryptor rlwe.D
 	}, evk
 }
 
-func NewBFVPasta(polyDegree, pastaSeclevel, messageLength, bsGsN1, bsGsN2, modulus uint64, sk *rlwe.SecretKey,
+// NewBFVPasta builds a client side instance, it needs the bfv secret key to
+// generate the galois keys so it must never run on the server. Servers should
+// use NewBFVPastaServer with keys from GenTranscipherKeys instead.
+// The galois keys are the ones of the default TranscipherOptions.
+func NewBFVPasta(polyDegree, pastaSeclevel, messageLength, modulus uint64, sk *rlwe.SecretKey,
 	rk *rlwe.RelinearizationKey) (rlwe.Encryptor, rlwe.Decryptor, bfv.Evaluator, bfv.Encoder, Params,
 	rlwe.EvaluationKeySet) {
 	bfvParams := GenerateBfvParams(modulus, polyDegree)
 	bfvEncoder := bfv.NewEncoder(bfvParams)
-	evk := evaluationKeysBfvPasta(messageLength, pastaSeclevel, polyDegree, true,
-		bsGsN2, bsGsN1, *sk, bfvParams, rk)
+	evk := evaluationKeysBfvPasta(messageLength, pastaSeclevel, TranscipherOptions{}, *sk, bfvParams, rk)
 	bfvEvaluator := bfv.NewEvaluator(bfvParams, &evk)
 
 	kg := rlwe.NewKeyGenerator(bfvParams.Parameters)
@@ -46,6 +53,8 @@ func NewBFVPasta(polyDegree, pastaSeclevel, messageLength, bsGsN1, bsGsN2, modul
 	return e, d, ev, en, params, evks
 }
 
+// NewBFVPastaEvks builds an instance from already generated evaluation keys,
+// no secret material is involved
 func NewBFVPastaEvks(polyDegree, modulus uint64, evks rlwe.EvaluationKeySet, pk *rlwe.PublicKey) (rlwe.Encryptor,
 	rlwe.Decryptor, bfv.Evaluator, bfv.Encoder, Params, rlwe.EvaluationKeySet) {
 
@@ -53,7 +62,11 @@ func NewBFVPastaEvks(polyDegree, modulus uint64, evks rlwe.EvaluationKeySet, pk
 	bfvEncoder := bfv.NewEncoder(bfvParams)
 	bfvEvaluator := bfv.NewEvaluator(bfvParams, &evks)
 
-	encryptor := bfv.NewEncryptor(bfvParams, pk)
+	// pk is optional, transciphering doesn't encrypt anything
+	var encryptor rlwe.Encryptor
+	if pk != nil {
+		encryptor = bfv.NewEncryptor(bfvParams, pk)
+	}
 
 	e, d, ev, en, params, evks2 := NewBFV(bfvParams, encryptor, nil, bfvEvaluator, bfvEncoder, evks)
 
@@ -66,63 +79,100 @@ func NewBFVPastaEvks(polyDegree, modulus uint64, evks rlwe.EvaluationKeySet, pk
 // return different ciphertexts. This is because GaloisKeys are generated in
 // a non-deterministic way.
 // More details about this https://github.com/tuneinsight/lattigo/discussions/397
+// Messages longer than TranscipherCapacity are packed into several
+// ciphertexts, use DecryptPacked to put them back together.
 func Transcipher(encryptedMessage []uint64, pastaSecretKey *rlwe.Ciphertext,
 	pastaParams pasta.Params, pastaSeclevel uint64, encoder bfv.Encoder,
-	evaluator bfv.Evaluator, bfvParams bfv.Parameters) rlwe.Ciphertext {
+	evaluator bfv.Evaluator, bfvParams bfv.Parameters) []PackedCiphertext {
+
+	packed, err := TranscipherScratch(encryptedMessage, pastaSecretKey, pastaParams, pastaSeclevel, encoder,
+		evaluator, NewScratch(bfvParams), TranscipherOptions{})
+	if err != nil { // the zero options are always valid
+		panic(err)
+	}
 
-	useBsGs := true // enables babystep gigantstep matrix multiplication
+	return packed
+}
+
+// TranscipherOptions tunes how the transcipher circuit is evaluated, the zero
+// value is what Transcipher uses
+type TranscipherOptions struct {
+	Matmul  MatmulStrategy
+	Bsgs    BsgsOptions
+	Packing Packing
+	Layout  Layout
+}
 
+// Validate checks the options can transcipher a message of messageLength
+// elements: a known matmul strategy and packing, a BSGS split covering the
+// pasta diagonals (unless it's MatmulDiagonal) and a layout fitting in a row
+// (see ValidateLayout).
+func (o TranscipherOptions) Validate(bfvParams bfv.Parameters, messageLength uint64) error {
+	switch o.Matmul {
+	case MatmulBsgs, MatmulDiagonal, MatmulAuto:
+	default:
+		return fmt.Errorf("unknown matmul strategy %s", o.Matmul)
+	}
+	if o.Packing != PackFirstRow && o.Packing != PackBothRows {
+		return fmt.Errorf("unknown packing %d", int(o.Packing))
+	}
+	if o.Matmul != MatmulDiagonal {
+		if err := o.Bsgs.validate(); err != nil {
+			return err
+		}
+	}
+
+	return ValidateLayout(bfvParams, messageLength, o)
+}
+
+// TranscipherScratch is Transcipher evaluated in place over the buffers of
+// scratch, reusing the same scratch across calls avoids allocating them again
+// for every message. It fails on options that can't be evaluated (see
+// TranscipherOptions.Validate) before doing any work.
+func TranscipherScratch(encryptedMessage []uint64, pastaSecretKey *rlwe.Ciphertext,
+	pastaParams pasta.Params, pastaSeclevel uint64, encoder bfv.Encoder,
+	evaluator bfv.Evaluator, scratch *Scratch, opts TranscipherOptions) ([]PackedCiphertext, error) {
+
+	return TranscipherWithNonce(encryptedMessage, pasta.Nonce, pastaSecretKey, pastaParams, pastaSeclevel,
+		encoder, evaluator, scratch, opts)
+}
+
+// TranscipherWithNonce is TranscipherScratch for a message pasta encrypted
+// with nonce (see pasta.EncryptWithNonce), messages under the same pasta key
+// must not share a nonce.
+func TranscipherWithNonce(encryptedMessage []uint64, nonce uint64, pastaSecretKey *rlwe.Ciphertext,
+	pastaParams pasta.Params, pastaSeclevel uint64, encoder bfv.Encoder,
+	evaluator bfv.Evaluator, scratch *Scratch, opts TranscipherOptions) ([]PackedCiphertext, error) {
+
+	bfvParams := scratch.params
+	if err := opts.Validate(bfvParams, uint64(len(encryptedMessage))); err != nil {
+		return nil, err
+	}
+	matmul := resolveMatmul(opts.Matmul, opts.Bsgs, bfvParams, evaluator)
 	pastaUtil := pasta.NewUtil(nil, bfvParams.T(), int(pastaParams.Rounds)) // todo(fedejinich) plainMod == b.bfvParams.T() == pastaParams.Modulus ?
 
 	encryptedMessageLength := uint64(len(encryptedMessage))
 
 	numBlock := int(math.Ceil(float64(encryptedMessageLength) / float64(pastaParams.CiphertextSize)))
 
-	fmt.Printf("Transciphering %d pasta blocks\n", numBlock)
+	fmt.Fprintf(util.Progress, "Transciphering %d pasta blocks\n", numBlock)
+
+	// 'state' contains two PASTA branches encoded as b.ciphertext
+	// s1 := pastaSecretKey[0:halfslots]
+	// s2 := pastaSecretKey[:halfslots]
+	state := scratch.ciphertext()
+	defer scratch.release(state)
 
 	result := make([]rlwe.Ciphertext, numBlock) // each element represents a pasta decrypted block
 	for block := 0; block < numBlock; block++ {
-		pastaUtil.InitShake(pasta.Nonce, uint64(block))
-
-		// 'state' contains two PASTA branches encoded as b.ciphertext
-		// s1 := pastaSecretKey[0:halfslots]
-		// s2 := pastaSecretKey[:halfslots]
-		state := pastaSecretKey
-
-		fmt.Printf("block %d/%d\n", block, numBlock)
-
-		slots := uint64(bfvParams.N())
-		halfslots := slots / 2
-		for r := 1; r <= int(pastaParams.Rounds); r++ {
-			fmt.Printf("round %d\n", r)
-
-			mat1 := pastaUtil.RandomMatrix()
-			mat2 := pastaUtil.RandomMatrix()
-			rc := pastaUtil.RCVec(halfslots)
-
-			state = Matmul(state, mat1, mat2, slots, halfslots, evaluator,
-				encoder, bfvParams, useBsGs)
-			state = AddRc(state, rc, encoder, evaluator, bfvParams)
-			state = Mix(state, evaluator, encoder)
-
-			if r == int(pastaParams.Rounds) {
-				state = SboxCube(state, evaluator)
-			} else {
-				state = SboxFeistel(state, halfslots, evaluator, encoder,
-					bfvParams)
-			}
-		}
+		pastaUtil.InitShake(nonce, uint64(block))
 
-		fmt.Println("final add")
+		state.Copy(pastaSecretKey)
 
-		mat1 := pastaUtil.RandomMatrix()
-		mat2 := pastaUtil.RandomMatrix()
-		rc := pastaUtil.RCVec(halfslots)
+		fmt.Fprintf(util.Progress, "block %d/%d\n", block, numBlock)
 
-		state = Matmul(state, mat1, mat2, slots, halfslots, evaluator, encoder,
-			bfvParams, useBsGs)
-		state = AddRc(state, rc, encoder, evaluator, bfvParams)
-		state = Mix(state, evaluator, encoder)
+		pastaRounds(state, []pasta.Util{pastaUtil}, int(pastaParams.Rounds), matmul, opts.Bsgs, scratch,
+			encoder, evaluator)
 
 		// add cipher
 		start := 0 + (block * int(pastaParams.CiphertextSize))
@@ -130,25 +180,199 @@ func Transcipher(encryptedMessage []uint64, pastaSecretKey *rlwe.Ciphertext,
 			float64(encryptedMessageLength))
 		cipherTmp := encryptedMessage[start:int(end)]
 
-		plaintext := bfv.NewPlaintext(bfvParams, bfvParams.MaxLevel())
-		encoder.Encode(cipherTmp, plaintext)
-		state = evaluator.NegNew(state)
-		result[block] = *evaluator.AddNew(state, plaintext) // ct + pt
+		result[block] = *bfv.NewCiphertext(bfvParams, 1, bfvParams.MaxLevel())
+		evaluator.Neg(state, &result[block])
+		evaluator.Add(&result[block], scratch.encode(cipherTmp, encoder), &result[block]) // ct + pt
+	}
+
+	packed := packPastaBlocks(result, pastaSeclevel, encryptedMessageLength, opts, evaluator, encoder, scratch)
+	if !opts.Layout.contiguous() {
+		// ValidateLayout made sure the whole message is in packed[0]
+		applyLayout(packed[0].Ciphertext, encryptedMessageLength, opts.Layout, evaluator, encoder, scratch)
+		packed[0].Layout = opts.Layout
+	}
+
+	return packed, nil
+}
+
+// pastaRounds evaluates the pasta permutation, without the key addition, on
+// the instances of state. Instance m sits at slot m*BatchWindow of each row
+// and draws its matrices and round constants from utils[m], whose shake must
+// already be initialized with the nonce and block counter of the instance.
+func pastaRounds(state *rlwe.Ciphertext, utils []pasta.Util, rounds int, matmul MatmulStrategy,
+	bsgs BsgsOptions, scratch *Scratch, encoder bfv.Encoder, evaluator bfv.Evaluator) {
+
+	halfslots := uint64(scratch.params.N() / 2)
+	affine := func() {
+		mats := make([]pastaMatrices, len(utils))
+		rc := make([]uint64, 2*halfslots)
+		for m := range utils {
+			mats[m] = pastaMatrices{utils[m].RandomMatrix(), utils[m].RandomMatrix()}
+			instanceRc := utils[m].RCVec(halfslots)
+			offset := uint64(m) * BatchWindow
+			copy(rc[offset:offset+pasta.T], instanceRc[:pasta.T])
+			copy(rc[halfslots+offset:halfslots+offset+pasta.T], instanceRc[halfslots:])
+		}
+
+		matmulInPlace(state, mats, matmul, bsgs, scratch, encoder, evaluator)
+		addRcInPlace(state, rc, scratch, encoder, evaluator)
+		mixInPlace(state, scratch, evaluator)
+	}
+
+	for r := 1; r <= rounds; r++ {
+		fmt.Fprintf(util.Progress, "round %d\n", r)
+
+		affine()
+		if r == rounds {
+			sboxCubeInPlace(state, scratch, evaluator)
+		} else {
+			sboxFeistelInPlace(state, len(utils), scratch, encoder, evaluator)
+		}
+	}
+
+	fmt.Fprintln(util.Progress, "final add")
+
+	affine()
+}
+
+// Packing selects which slots of the output ciphertexts transciphered pasta
+// blocks are packed into, both modes use the same galois keys
+type Packing int
+
+const (
+	// PackFirstRow packs blocks into the first row (slots [0, N/2)) only,
+	// the second row is left with garbage
+	PackFirstRow Packing = iota
+	// PackBothRows fills the first row and carries on in the second one,
+	// doubling the elements per ciphertext at the cost of a row rotation and
+	// masking the garbage out of both rows
+	PackBothRows
+)
+
+// PackedCiphertext is one of the ciphertexts a transciphered message is
+// packed into, it holds the message elements [Start, End). The first
+// RowLength of them are in the first row, placed as Layout says, and the rest
+// in the first slots of the second row, use Decode to get them in order.
+type PackedCiphertext struct {
+	Ciphertext *rlwe.Ciphertext
+	Start, End uint64
+	RowLength  uint64
+	Layout     Layout
+}
+
+// Decode picks the message elements out of the decoded slots of p.Ciphertext
+func (p PackedCiphertext) Decode(slots []uint64) []uint64 {
+	halfslots := uint64(len(slots) / 2)
+	message := make([]uint64, p.End-p.Start)
+	for k := uint64(0); k < p.RowLength; k++ {
+		message[k] = slots[p.Layout.slot(k)]
+	}
+	copy(message[p.RowLength:], slots[halfslots:])
+
+	return message
+}
+
+// TranscipherCapacity returns how many message elements fit in one
+// transciphered ciphertext, pasta blocks are packed whole into each row
+func TranscipherCapacity(bfvParams bfv.Parameters, pastaSeclevel uint64, packing Packing) uint64 {
+	rows := uint64(1)
+	if packing == PackBothRows {
+		rows = 2
+	}
+
+	return rows * blocksPerRow(bfvParams, pastaSeclevel) * pastaSeclevel
+}
+
+func blocksPerRow(bfvParams bfv.Parameters, pastaSeclevel uint64) uint64 {
+	return uint64(bfvParams.N()/2) / pastaSeclevel
+}
+
+// DecryptPacked decrypts a transciphered message spanning one or more
+// ciphertexts and puts it back together in order
+func DecryptPacked(ciphertexts []PackedCiphertext, decryptor rlwe.Decryptor, encoder bfv.Encoder) []uint64 {
+	var size uint64
+	for _, p := range ciphertexts {
+		if p.End > size {
+			size = p.End
+		}
+	}
+
+	message := make([]uint64, size)
+	for _, p := range ciphertexts {
+		slots := encoder.DecodeUintNew(decryptor.DecryptNew(p.Ciphertext))
+		copy(message[p.Start:p.End], p.Decode(slots))
 	}
 
-	// flatten pasta blocks
-	ciphertext := flattenPastaBlocks(result, pastaSeclevel, encryptedMessageLength,
-		evaluator, encoder, bfvParams)
+	return message
+}
 
-	return ciphertext
+// Unpacked describes a ciphertext holding a message in its first size slots
+// for DecryptPacked, a size past N/2 carries on in the second row (up to N,
+// every slot in order)
+func Unpacked(ciphertext *rlwe.Ciphertext, size uint64) []PackedCiphertext {
+	return []PackedCiphertext{{Ciphertext: ciphertext, End: size, RowLength: size}}
 }
 
-func DecryptPacked(ciphertext *rlwe.Ciphertext, size uint64,
-	decryptor rlwe.Decryptor, encoder bfv.Encoder) []uint64 {
-	plaintext := decryptor.DecryptNew(ciphertext)
-	dec := encoder.DecodeUintNew(plaintext)
+// MarshalPackedCiphertexts encodes a transciphered message big endian as
+// count | (start | end | row length | len(ct) | ct)..., only the default
+// (contiguous) layout can be encoded
+func MarshalPackedCiphertexts(packed []PackedCiphertext) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, uint64(len(packed))); err != nil {
+		return nil, err
+	}
+	for _, p := range packed {
+		if !p.Layout.contiguous() {
+			return nil, errors.New("only contiguous layouts can be encoded")
+		}
+		ct, err := p.Ciphertext.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range []interface{}{p.Start, p.End, p.RowLength, uint64(len(ct)), ct} {
+			if err := binary.Write(&buf, binary.BigEndian, v); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-	return dec[:size]
+	return buf.Bytes(), nil
+}
+
+// UnmarshalPackedCiphertexts decodes a message encoded with
+// MarshalPackedCiphertexts
+func UnmarshalPackedCiphertexts(data []byte, bfvParams bfv.Parameters) ([]PackedCiphertext, error) {
+	r := bytes.NewReader(data)
+	malformed := errors.New("malformed packed ciphertexts")
+	var count uint64
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, malformed
+	}
+
+	var packed []PackedCiphertext
+	for i := uint64(0); i < count; i++ {
+		var start, end, rowLength, ctLen uint64
+		for _, v := range []interface{}{&start, &end, &rowLength, &ctLen} {
+			if err := binary.Read(r, binary.BigEndian, v); err != nil {
+				return nil, malformed
+			}
+		}
+		if end < start || rowLength > end-start || ctLen > uint64(r.Len()) {
+			return nil, malformed
+		}
+		ctBytes := make([]byte, ctLen)
+		if _, err := r.Read(ctBytes); err != nil {
+			return nil, malformed
+		}
+
+		ct := bfv.NewCiphertext(bfvParams, 1, bfvParams.MaxLevel())
+		if err := ct.UnmarshalBinary(ctBytes); err != nil {
+			return nil, err
+		}
+		packed = append(packed, PackedCiphertext{Ciphertext: ct, Start: start, End: end, RowLength: rowLength})
+	}
+
+	return packed, nil
 }
 
 func EncryptPastaSecretKey(secretKey []uint64, encoder bfv.Encoder,
@@ -168,97 +392,131 @@ func EncryptPastaSecretKey(secretKey []uint64, encoder bfv.Encoder,
 	return encryptor.EncryptNew(plaintext)
 }
 
-// flattenPastaBlocks creates and applies a masking vector and flattens
-// transciphered pasta blocks into one ciphertext
+// packPastaBlocks flattens transciphered pasta blocks into as few ciphertexts
+// as their slots allow, each one holding a contiguous range of the message
+func packPastaBlocks(pastaBlocks []rlwe.Ciphertext, pastaSeclevel, messageLength uint64, opts TranscipherOptions,
+	evaluator bfv.Evaluator, encoder bfv.Encoder, scratch *Scratch) []PackedCiphertext {
+
+	numBlock := uint64(len(pastaBlocks))
+	perRow := blocksPerRow(scratch.params, pastaSeclevel)
+	perCiphertext := TranscipherCapacity(scratch.params, pastaSeclevel, opts.Packing) / pastaSeclevel
+	// spreading the elements for a strided layout already drops the garbage
+	// at the end of the last block, masking it twice would waste noise budget
+	maskTail := opts.Layout.stride() == 1
+
+	packed := make([]PackedCiphertext, 0, (numBlock+perCiphertext-1)/perCiphertext)
+	for first := uint64(0); first < numBlock; first += perCiphertext {
+		last := minUint64(first+perCiphertext, numBlock)
+		start := first * pastaSeclevel
+		end := minUint64(last*pastaSeclevel, messageLength)
+
+		// blocks only spill into the second row when the first one is full
+		rowEnd := minUint64(first+perRow, last)
+		rowLength := minUint64(rowEnd*pastaSeclevel, end) - start
+
+		var ciphertext rlwe.Ciphertext
+		if rowEnd == last {
+			ciphertext = flattenPastaBlocks(pastaBlocks[first:last], pastaSeclevel, end-start, maskTail, evaluator,
+				encoder, scratch)
+		} else {
+			ciphertext = flattenPastaRows(pastaBlocks[first:rowEnd], pastaBlocks[rowEnd:last], pastaSeclevel,
+				end-start-rowLength, evaluator, encoder, scratch)
+		}
+		packed = append(packed, PackedCiphertext{Ciphertext: &ciphertext, Start: start, End: end,
+			RowLength: rowLength})
+	}
+
+	return packed
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// flattenPastaBlocks creates and applies a masking vector (unless maskTail is
+// false) and flattens transciphered pasta blocks into one ciphertext
 func flattenPastaBlocks(pastaBlocks []rlwe.Ciphertext, pastaSeclevel,
-	messageLength uint64, evaluator bfv.Evaluator, encoder bfv.Encoder,
-	bfvParams bfv.Parameters) rlwe.Ciphertext {
+	messageLength uint64, maskTail bool, evaluator bfv.Evaluator, encoder bfv.Encoder,
+	scratch *Scratch) rlwe.Ciphertext {
 
 	rem := messageLength % pastaSeclevel
 
-	if rem != 0 {
-		mask := make([]uint64, rem) // create a 1s mask
-		for i := range mask {
-			mask[i] = 1
-		}
-		lastIndex := len(pastaBlocks) - 1
-		last := pastaBlocks[lastIndex].CopyNew()
-		plaintext := bfv.NewPlaintext(bfvParams, bfvParams.MaxLevel())
-		encoder.Encode(mask, plaintext)
+	if rem != 0 && maskTail {
+		last := &pastaBlocks[len(pastaBlocks)-1]
 
 		// mask
-		pastaBlocks[lastIndex] = *evaluator.MulNew(last, plaintext) // ct x pt
+		evaluator.Mul(last, firstRowMask(rem, scratch, encoder), last) // ct x pt
 	}
 
-	// flatten ciphertexts
-	ciphertext := pastaBlocks[0]
-	for i := 1; i < len(pastaBlocks); i++ {
-		tmp := evaluator.
-			RotateColumnsNew(&pastaBlocks[i], -(i * int(pastaSeclevel)))
-		ciphertext = *evaluator.AddNew(&ciphertext, tmp) // ct + ct
-	}
-
-	return ciphertext
+	return sumPastaBlocks(pastaBlocks, pastaSeclevel, evaluator, scratch)
 }
 
-func (b *Params) slots() uint64 {
-	return uint64(b.Params.N())
-}
+// flattenPastaRows flattens a full first row of pasta blocks and the blocks
+// for the second row into one ciphertext. Each block also carries garbage in
+// the second row, so both sums are masked before the second one is moved
+// there by a row rotation.
+func flattenPastaRows(firstRow, secondRow []rlwe.Ciphertext, pastaSeclevel, secondRowLength uint64,
+	evaluator bfv.Evaluator, encoder bfv.Encoder, scratch *Scratch) rlwe.Ciphertext {
 
-func (b *Params) halfslots() uint64 {
-	return b.slots() / 2
-}
+	first := sumPastaBlocks(firstRow, pastaSeclevel, evaluator, scratch)
+	second := sumPastaBlocks(secondRow, pastaSeclevel, evaluator, scratch)
 
-// evaluationKeysBfvPasta creates evaluation keys (for rotations and relinearization) to transcipher from pasta to bfv
-func evaluationKeysBfvPasta(messageLength uint64, pastaSeclevel uint64, modDegree uint64, useBsGs bool, bsGsN2 uint64,
-	bsGsN1 uint64, secretKey rlwe.SecretKey, bfvParams bfv.Parameters, rk *rlwe.RelinearizationKey) rlwe.EvaluationKeySet {
+	evaluator.Mul(&first, firstRowMask(uint64(len(firstRow))*pastaSeclevel, scratch, encoder), &first) // ct x pt
+	evaluator.Mul(&second, firstRowMask(secondRowLength, scratch, encoder), &second)                   // ct x pt
 
-	rem := messageLength % pastaSeclevel
+	tmp := scratch.ciphertext()
+	evaluator.RotateRows(&second, tmp)
+	evaluator.Add(&first, tmp, &first) // ct + ct
+	scratch.release(tmp)
 
-	numBlock := int64(messageLength / pastaSeclevel)
-	if rem > 0 {
-		numBlock++
-	}
-	var flattenGks []int
-	for i := int64(1); i < numBlock; i++ {
-		flattenGks = append(flattenGks, -int(i*int64(pastaSeclevel)))
+	return first
+}
+
+// firstRowMask encodes a mask keeping the first n slots of the first row, it's
+// only valid until the next scratch.encode
+func firstRowMask(n uint64, scratch *Scratch, encoder bfv.Encoder) *rlwe.Plaintext {
+	mask := scratch.slotValues()
+	for i := uint64(0); i < n; i++ {
+		mask[i] = 1
 	}
 
-	var gkIndices []int
-	gkIndices = addGkIndices(gkIndices, modDegree, useBsGs)
+	return scratch.encode(mask, encoder)
+}
 
-	// add flatten gks
-	for i := 0; i < len(flattenGks); i++ {
-		gkIndices = append(gkIndices, flattenGks[i])
-	}
+// sumPastaBlocks rotates block i by i*pastaSeclevel slots and adds them all
+// up into the first block
+func sumPastaBlocks(pastaBlocks []rlwe.Ciphertext, pastaSeclevel uint64, evaluator bfv.Evaluator,
+	scratch *Scratch) rlwe.Ciphertext {
 
-	if useBsGs {
-		addBsGsIndices(bsGsN1, bsGsN2, &gkIndices, modDegree)
-	} else {
-		addDiagonalIndices(messageLength, &gkIndices, modDegree)
+	ciphertext := &pastaBlocks[0]
+	tmp := scratch.ciphertext()
+	for i := 1; i < len(pastaBlocks); i++ {
+		evaluator.RotateColumns(&pastaBlocks[i], -(i * int(pastaSeclevel)), tmp)
+		evaluator.Add(ciphertext, tmp, ciphertext) // ct + ct
 	}
+	scratch.release(tmp)
 
-	// finally we create the right evaluation set (rotation & reliniarization keys)
-	evk := buildEvks(gkIndices, bfvParams.Parameters, &secretKey, rk)
+	return *ciphertext
+}
 
-	return *evk
+func (b *Params) slots() uint64 {
+	return uint64(b.Params.N())
 }
 
-func buildEvks(gkIndices []int, params rlwe.Parameters, secretKey *rlwe.SecretKey,
-	rk *rlwe.RelinearizationKey) *rlwe.EvaluationKeySet {
+func (b *Params) halfslots() uint64 {
+	return b.slots() / 2
+}
 
-	galEls := make([]uint64, len(gkIndices))
-	for i, rot := range gkIndices {
-		// SEAL uses gkIndex = 0 to represent a column rotation (row in lattigo)
-		//    we fix this by generating the right gk for 0 elements
-		if rot == 0 {
-			galEls[i] = params.GaloisElementForRowRotation()
-		} else {
-			galEls[i] = params.GaloisElementForColumnRotationBy(rot)
-		}
-	}
+// evaluationKeysBfvPasta creates evaluation keys (for rotations and relinearization) to transcipher from pasta to bfv,
+// it only generates the galois keys the circuit uses with opts (see TranscipherGaloisElements)
+func evaluationKeysBfvPasta(messageLength, pastaSeclevel uint64, opts TranscipherOptions, secretKey rlwe.SecretKey,
+	bfvParams bfv.Parameters, rk *rlwe.RelinearizationKey) rlwe.EvaluationKeySet {
 
-	evk := GenEvks(params, galEls, secretKey, rk)
+	galEls := TranscipherGaloisElements(bfvParams.Parameters, messageLength, pastaSeclevel, opts)
 
-	return evk
+	return *GenEvks(bfvParams.Parameters, galEls, &secretKey, rk)
 }
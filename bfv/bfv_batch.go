@@ -0,0 +1,181 @@
+package bfv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// BatchWindow is the number of slots of each row taken by one message of a
+// batch: pasta.T for the pasta state and pasta.T more for the copy matmul
+// rotates from.
+const BatchWindow = 2 * pasta.T
+
+// BatchMessage is a short pasta ciphertext, at most one block long, and the
+// nonce it was encrypted with
+type BatchMessage struct {
+	Ciphertext []uint64
+	Nonce      uint64
+}
+
+// BatchCiphertext is the output of TranscipherBatch. Message m is in the
+// first row, in the slots [m*BatchWindow, m*BatchWindow+Lengths[m]), every
+// other slot is zero.
+type BatchCiphertext struct {
+	Ciphertext *rlwe.Ciphertext
+	Lengths    []uint64
+}
+
+// Decode picks the messages out of the decoded slots of b.Ciphertext
+func (b BatchCiphertext) Decode(slots []uint64) [][]uint64 {
+	messages := make([][]uint64, len(b.Lengths))
+	for m, length := range b.Lengths {
+		offset := uint64(m) * BatchWindow
+		messages[m] = make([]uint64, length)
+		copy(messages[m], slots[offset:offset+length])
+	}
+
+	return messages
+}
+
+// DecryptBatch decrypts a batch transciphered ciphertext into its messages
+func DecryptBatch(b BatchCiphertext, decryptor rlwe.Decryptor, encoder bfv.Encoder) [][]uint64 {
+	return b.Decode(encoder.DecodeUintNew(decryptor.DecryptNew(b.Ciphertext)))
+}
+
+// BatchCapacity returns how many messages TranscipherBatch fits in one
+// ciphertext
+func BatchCapacity(bfvParams bfv.Parameters) uint64 {
+	return uint64(bfvParams.N()/2) / BatchWindow
+}
+
+// batchReplicas returns the number of copies of the pasta key a batch of n
+// messages needs, a power of two so every batch size uses a prefix of the
+// same doubling rotations
+func batchReplicas(n uint64) uint64 {
+	r := uint64(1)
+	for r < n {
+		r <<= 1
+	}
+
+	return r
+}
+
+// batchRotations returns the rotations performed by TranscipherBatch, on top
+// of the ones of the pasta rounds
+func batchRotations(slots uint64) []int {
+	capacity := slots / 2 / BatchWindow
+	if capacity < 2 {
+		return nil
+	}
+
+	return layoutRotations(BatchWindow, Layout{Replication: capacity})
+}
+
+// BatchGaloisElements returns the sorted set of galois elements
+// TranscipherBatch uses with opts, for batches of any size
+func BatchGaloisElements(params rlwe.Parameters, opts TranscipherOptions) []uint64 {
+	slots := uint64(params.N())
+	rots := transcipherRotations(slots, pasta.CiphertextSize, pasta.DefaultSecLevel, opts)
+
+	return galoisElements(params, append(rots, batchRotations(slots)...))
+}
+
+// GenBatchTranscipherKeys is GenTranscipherKeys for TranscipherBatch
+func GenBatchTranscipherKeys(bfvParams bfv.Parameters, sk *rlwe.SecretKey,
+	opts TranscipherOptions) rlwe.EvaluationKeySet {
+	kgen := rlwe.NewKeyGenerator(bfvParams.Parameters)
+	rk := kgen.GenRelinearizationKeyNew(sk)
+
+	return *GenEvks(bfvParams.Parameters, BatchGaloisElements(bfvParams.Parameters, opts), sk, rk)
+}
+
+// TranscipherBatch transciphers many short messages, all under the pasta key
+// encrypted in pastaSecretKey but each with its own nonce, in a single pass
+// of the pasta rounds. Message m takes the m-th BatchWindow slots of both
+// rows, so up to BatchCapacity messages of at most pasta.CiphertextSize
+// elements fit in one batch. Only the Matmul and Bsgs options apply, the
+// output is always laid out as BatchCiphertext describes. It fails, before
+// doing any work, on an empty or oversized batch, a message longer than a
+// block, two messages sharing a nonce or invalid options.
+func TranscipherBatch(messages []BatchMessage, pastaSecretKey *rlwe.Ciphertext, pastaParams pasta.Params,
+	encoder bfv.Encoder, evaluator bfv.Evaluator, scratch *Scratch, opts TranscipherOptions) (BatchCiphertext,
+	error) {
+
+	bfvParams := scratch.params
+	if err := validateBatch(messages, bfvParams, pastaParams); err != nil {
+		return BatchCiphertext{}, err
+	}
+	opts = TranscipherOptions{Matmul: opts.Matmul, Bsgs: opts.Bsgs}
+	if err := opts.Validate(bfvParams, pastaParams.CiphertextSize); err != nil {
+		return BatchCiphertext{}, err
+	}
+	n := uint64(len(messages))
+	matmul := resolveMatmul(opts.Matmul, opts.Bsgs, bfvParams, evaluator)
+
+	fmt.Fprintf(util.Progress, "Transciphering a batch of %d messages\n", n)
+
+	utils := make([]pasta.Util, n)
+	cipher := make([]uint64, bfvParams.N())
+	mask := make([]uint64, bfvParams.N())
+	lengths := make([]uint64, n)
+	for m, message := range messages {
+		length := uint64(len(message.Ciphertext))
+		utils[m] = pasta.NewUtil(nil, bfvParams.T(), int(pastaParams.Rounds))
+		utils[m].InitShake(message.Nonce, 0)
+
+		offset := uint64(m) * BatchWindow
+		copy(cipher[offset:], message.Ciphertext)
+		for k := uint64(0); k < length; k++ {
+			mask[offset+k] = 1
+		}
+		lengths[m] = length
+	}
+
+	// one copy of the key per instance
+	state := bfv.NewCiphertext(bfvParams, 1, bfvParams.MaxLevel())
+	state.Copy(pastaSecretKey)
+	if replicas := batchReplicas(n); replicas > 1 {
+		applyLayout(state, BatchWindow, Layout{Replication: replicas}, evaluator, encoder, scratch)
+	}
+
+	pastaRounds(state, utils, int(pastaParams.Rounds), matmul, opts.Bsgs, scratch, encoder, evaluator)
+
+	// message - keystream, the keystream left in the rest of each window and
+	// in the second row is masked out
+	evaluator.Neg(state, state)
+	evaluator.Add(state, scratch.encode(cipher, encoder), state) // ct + pt
+	evaluator.Mul(state, scratch.encode(mask, encoder), state)   // ct x pt
+
+	return BatchCiphertext{Ciphertext: state, Lengths: lengths}, nil
+}
+
+// validateBatch checks messages fit in one batch, each with its own nonce
+// since two messages sharing one would share their keystream too
+func validateBatch(messages []BatchMessage, bfvParams bfv.Parameters, pastaParams pasta.Params) error {
+	n := uint64(len(messages))
+	if n == 0 {
+		return errors.New("empty batch")
+	}
+	if capacity := BatchCapacity(bfvParams); n > capacity {
+		return fmt.Errorf("a batch holds at most %d messages, got %d", capacity, n)
+	}
+
+	nonces := make(map[uint64]int, n)
+	for m, message := range messages {
+		if length := uint64(len(message.Ciphertext)); length > pastaParams.CiphertextSize {
+			return fmt.Errorf("message %d has %d elements, batched messages take at most one pasta block (%d)",
+				m, length, pastaParams.CiphertextSize)
+		}
+		if other, ok := nonces[message.Nonce]; ok {
+			return fmt.Errorf("messages %d and %d share the nonce %d", other, m, message.Nonce)
+		}
+		nonces[message.Nonce] = m
+	}
+
+	return nil
+}
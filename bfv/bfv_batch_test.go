@@ -0,0 +1,83 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestTranscipherBatch(t *testing.T) {
+	tc := testCases()[1]
+	bfvParams := GenerateBfvParams(tc.modulus, tc.bfvPolyDegree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	evks := GenBatchTranscipherKeys(bfvParams, sk, TranscipherOptions{})
+	encoder := bfv2.NewEncoder(bfvParams)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+	evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+	pastaSKCt := EncryptPastaSecretKey(tc.secretKey, encoder, bfv2.NewEncryptor(bfvParams, pk), bfvParams)
+	pastaCipher := pasta.NewPasta(tc.secretKey, tc.modulus, PastaParams)
+
+	plaintexts := [][]uint64{{1}, {2, 3}, RandomInputV(pasta.CiphertextSize, tc.modulus), {}, {4, 5, 6}}
+	messages := make([]BatchMessage, len(plaintexts))
+	for m, plaintext := range plaintexts {
+		nonce := uint64(1000 + m)
+		messages[m] = BatchMessage{Ciphertext: pastaCipher.EncryptWithNonce(plaintext, nonce), Nonce: nonce}
+	}
+
+	scratch := NewScratch(bfvParams)
+	res, err := TranscipherBatch(messages, pastaSKCt, PastaParams, encoder, evaluator, scratch, TranscipherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slots := encoder.DecodeUintNew(decryptor.DecryptNew(res.Ciphertext))
+	for m, decrypted := range res.Decode(slots) {
+		if !util.EqualSlices(decrypted, plaintexts[m]) {
+			t.Errorf("message %d: decrypted %v, expected %v", m, decrypted, plaintexts[m])
+		}
+		// clear the message so only the zeroed slots are left
+		for k := range decrypted {
+			slots[uint64(m)*BatchWindow+uint64(k)] = 0
+		}
+	}
+	for i, v := range slots {
+		if v != 0 {
+			t.Fatalf("slot %d outside the messages isn't zero", i)
+		}
+	}
+
+	// rejected before touching the keys
+	tooLong := BatchMessage{Ciphertext: make([]uint64, pasta.CiphertextSize+1)}
+	for name, batch := range map[string][]BatchMessage{
+		"an empty batch":     nil,
+		"an oversized batch": make([]BatchMessage, BatchCapacity(bfvParams)+1),
+		"a too long message": {tooLong},
+		"a reused nonce":     {messages[0], messages[1], messages[0]},
+	} {
+		if _, err := TranscipherBatch(batch, nil, PastaParams, nil, nil, scratch, TranscipherOptions{}); err == nil {
+			t.Errorf("expected an error for %s", name)
+		}
+	}
+	_, err = TranscipherBatch(messages, nil, PastaParams, nil, nil, scratch,
+		TranscipherOptions{Bsgs: BsgsOptions{N1: 3, N2: 5}})
+	if err == nil {
+		t.Errorf("expected an error for an invalid bsgs split")
+	}
+}
+
+func TestBatchGaloisElements(t *testing.T) {
+	params := GenerateBfvParams(65537, 1<<15).Parameters
+
+	// the transcipher keys of a single block plus log2(64) doubling rotations
+	expected := len(TranscipherGaloisElements(params, pasta.CiphertextSize, pasta.DefaultSecLevel,
+		TranscipherOptions{})) + 6
+	if n := len(BatchGaloisElements(params, TranscipherOptions{})); n != expected {
+		t.Errorf("expected %d galois elements, got %d", expected, n)
+	}
+	if c := BatchCapacity(GenerateBfvParams(65537, 1<<15)); c != 64 {
+		t.Errorf("expected a capacity of 64 messages, got %d", c)
+	}
+}
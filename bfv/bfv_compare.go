@@ -0,0 +1,155 @@
+package bfv
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// IsZero returns a ciphertext holding 1 on every slot where ct is zero and 0
+// elsewhere, it computes 1 - ct^(T-1) (Fermat's little theorem, T is prime).
+// It takes IsZeroDepth multiplications and the relinearization key.
+func IsZero(ct *rlwe.Ciphertext, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+	out := pow(ct, bfvParams.T()-1, evaluator)
+	evaluator.Neg(out, out)
+	evaluator.Add(out, uint64(1), out)
+
+	return out
+}
+
+// Equal returns a ciphertext holding 1 on every slot where ct1 and ct2 are
+// equal and 0 elsewhere, see IsZero
+func Equal(ct1, ct2 *rlwe.Ciphertext, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+	diff := evaluator.SubNew(ct1, ct2)
+
+	return IsZero(diff, evaluator, bfvParams)
+}
+
+// LessThan returns a ciphertext holding 1 on every slot where ct1 is lower
+// than ct2 and 0 elsewhere, both must hold values in [0, bound). The
+// difference ct1 - ct2 then takes one of 2*bound-1 values mod T, so the
+// result is the polynomial interpolating the comparison over them, of degree
+// 2*bound-2. It takes LessThanDepth multiplications and the relinearization
+// key, a small bound keeps it cheaper than IsZero.
+func LessThan(ct1, ct2 *rlwe.Ciphertext, bound uint64, evaluator bfv.Evaluator,
+	bfvParams bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	t := bfvParams.T()
+	if bound < 2 || bound > (t+1)/2 {
+		return nil, fmt.Errorf("bound must be between 2 and %d, got %d", (t+1)/2, bound)
+	}
+
+	// the differences -(bound-1)..bound-1, the negative ones mean lower
+	xs := make([]uint64, 2*bound-1)
+	ys := make([]uint64, 2*bound-1)
+	for i := range xs {
+		xs[i] = (t + uint64(i) - (bound - 1)) % t
+		if uint64(i) < bound-1 {
+			ys[i] = 1
+		}
+	}
+
+	diff := evaluator.SubNew(ct1, ct2)
+
+	return EvaluatePoly(diff, interpolate(xs, ys, t), evaluator, bfvParams)
+}
+
+// IsZeroDepth returns the multiplicative depth of IsZero and Equal
+func IsZeroDepth(t uint64) int {
+	e := t - 1
+	depth := bits.Len64(e) - 1
+	if bits.OnesCount64(e) > 1 {
+		depth++
+	}
+
+	return depth
+}
+
+// LessThanDepth returns the multiplicative depth of LessThan for bound
+func LessThanDepth(bound uint64) int {
+	return PolyDepth(2*bound - 2)
+}
+
+// pow returns ct^e by square and multiply
+func pow(ct *rlwe.Ciphertext, e uint64, evaluator bfv.Evaluator) *rlwe.Ciphertext {
+	var acc *rlwe.Ciphertext
+	sq := ct.CopyNew()
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			if acc == nil {
+				acc = sq.CopyNew()
+			} else {
+				evaluator.MulRelin(acc, sq, acc)
+			}
+		}
+		if e > 1 {
+			evaluator.MulRelin(sq, sq, sq)
+		}
+	}
+
+	return acc
+}
+
+// interpolate returns the coefficients of the polynomial of degree
+// len(xs)-1 mod t going through every (xs[i], ys[i]), by Lagrange
+func interpolate(xs, ys []uint64, t uint64) []uint64 {
+	// prod(X - x_k)
+	full := []uint64{1}
+	for _, x := range xs {
+		next := make([]uint64, len(full)+1)
+		for i, c := range full {
+			next[i+1] = (next[i+1] + c) % t
+			next[i] = (next[i] + t - mulMod(c, x, t)) % t
+		}
+		full = next
+	}
+
+	coeffs := make([]uint64, len(xs))
+	basis := make([]uint64, len(xs))
+	for j, xj := range xs {
+		if ys[j] == 0 {
+			continue
+		}
+
+		// prod(X - x_k) / (X - x_j), by synthetic division
+		carry := uint64(0)
+		for i := len(full) - 1; i >= 1; i-- {
+			carry = (full[i] + mulMod(carry, xj, t)) % t
+			basis[i-1] = carry
+		}
+
+		denom := uint64(1)
+		for k, xk := range xs {
+			if k != j {
+				denom = mulMod(denom, (xj+t-xk)%t, t)
+			}
+		}
+		scale := mulMod(ys[j], powMod(denom, t-2, t), t)
+
+		for i, c := range basis {
+			coeffs[i] = (coeffs[i] + mulMod(scale, c, t)) % t
+		}
+	}
+
+	return coeffs
+}
+
+func mulMod(a, b, t uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+
+	return bits.Rem64(hi, lo, t)
+}
+
+func powMod(a, e, t uint64) uint64 {
+	res := uint64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			res = mulMod(res, a, t)
+		}
+		a = mulMod(a, a, t)
+	}
+
+	return res
+}
@@ -0,0 +1,81 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestCompare(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<15)
+	kgen := rlwe.NewKeyGenerator(bfvParams.Parameters)
+	sk, pk := kgen.GenKeyPairNew()
+	evks := rlwe.NewEvaluationKeySet()
+	evks.RelinearizationKey = kgen.GenRelinearizationKeyNew(sk)
+	evaluator := bfv2.NewEvaluator(bfvParams, evks)
+	encoder := bfv2.NewEncoder(bfvParams)
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+
+	encrypt := func(values []uint64) *rlwe.Ciphertext {
+		pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+		encoder.Encode(values, pt)
+		return encryptor.EncryptNew(pt)
+	}
+	decrypt := func(ct *rlwe.Ciphertext, n int) []uint64 {
+		return encoder.DecodeUintNew(decryptor.DecryptNew(ct))[:n]
+	}
+
+	a := encrypt([]uint64{0, 3, 7, 5, 0})
+	b := encrypt([]uint64{0, 5, 2, 5, 7})
+
+	if got := decrypt(IsZero(a, evaluator, bfvParams), 5); !util.EqualSlices(got, []uint64{1, 0, 0, 0, 1}) {
+		t.Errorf("IsZero %v", got)
+	}
+	if got := decrypt(Equal(a, b, evaluator, bfvParams), 5); !util.EqualSlices(got, []uint64{1, 0, 0, 1, 0}) {
+		t.Errorf("Equal %v", got)
+	}
+
+	lt, err := LessThan(a, b, 8, evaluator, bfvParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decrypt(lt, 5); !util.EqualSlices(got, []uint64{0, 1, 0, 0, 1}) {
+		t.Errorf("LessThan %v", got)
+	}
+	if _, err := LessThan(a, b, 1, evaluator, bfvParams); err == nil {
+		t.Errorf("expected an error for a bound below 2")
+	}
+	// 2*bound-1 would wrap around
+	for _, bound := range []uint64{(bfvParams.T()+1)/2 + 1, 1<<63 + 1} {
+		if _, err := LessThan(a, b, bound, evaluator, bfvParams); err == nil {
+			t.Errorf("expected an error for bound %d", bound)
+		}
+	}
+
+	if depth := IsZeroDepth(65537); depth != 16 {
+		t.Errorf("IsZero depth %d, expected 16", depth)
+	}
+	if depth := LessThanDepth(8); depth != 4 {
+		t.Errorf("LessThan depth %d, expected 4", depth)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	modulus := uint64(65537)
+	xs := []uint64{0, 1, 2, modulus - 1}
+	ys := []uint64{5, 0, 7, 1}
+	coeffs := interpolate(xs, ys, modulus)
+	for i, x := range xs {
+		y, xPow := uint64(0), uint64(1)
+		for _, c := range coeffs {
+			y = (y + mulMod(c, xPow, modulus)) % modulus
+			xPow = mulMod(xPow, x, modulus)
+		}
+		if y != ys[i] {
+			t.Errorf("p(%d) = %d, expected %d", x, y, ys[i])
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package bfv
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// GenTranscipherKeys is the client side of the transcipher setup. It derives
+// from sk the relinearization key and exactly the galois keys Transcipher
+// needs for messages of messageLength elements transciphered with opts
+// (MatmulAuto generates the keys of the cheapest strategy). The returned set
+// holds no secret material and is meant to be shipped to the server.
+func GenTranscipherKeys(bfvParams bfv.Parameters, sk *rlwe.SecretKey, messageLength uint64,
+	opts TranscipherOptions) rlwe.EvaluationKeySet {
+	rk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenRelinearizationKeyNew(sk)
+
+	return evaluationKeysBfvPasta(messageLength, pasta.DefaultSecLevel, opts, *sk, bfvParams, rk)
+}
+
+// NewBFVPastaServer is the server side of the transcipher setup, it only takes
+// public material (the evaluation keys generated by GenTranscipherKeys) and
+// returns what's needed to call Transcipher.
+func NewBFVPastaServer(polyDegree, modulus uint64, evks *rlwe.EvaluationKeySet) (bfv.Evaluator, bfv.Encoder,
+	Params, error) {
+
+	if evks == nil || evks.RelinearizationKey == nil {
+		return nil, nil, Params{}, errors.New("transcipher needs a relinearization key")
+	}
+	if len(evks.GaloisKeys) == 0 {
+		return nil, nil, Params{}, errors.New("transcipher needs galois keys")
+	}
+
+	bfvParams := GenerateBfvParams(modulus, polyDegree)
+	bfvEncoder := bfv.NewEncoder(bfvParams)
+	bfvEvaluator := bfv.NewEvaluator(bfvParams, evks)
+
+	return bfvEvaluator, bfvEncoder, Params{bfvParams, *evks}, nil
+}
+
+// transcipherRotations returns the rotations performed by the transcipher
+// circuit, following SEAL a 0 stands for the row rotation (used by Mix)
+func transcipherRotations(slots, messageLength, pastaSeclevel uint64, opts TranscipherOptions) []int {
+	rots := []int{0} // Mix
+	rots = append(rots, matmulRotations(slots, opts.Matmul, opts.Bsgs)...)
+
+	// flattenPastaBlocks, longer messages start over in a new ciphertext
+	numBlock := (messageLength + pastaSeclevel - 1) / pastaSeclevel
+	if perCiphertext := slots / 2 / pastaSeclevel; numBlock > perCiphertext {
+		numBlock = perCiphertext
+	}
+	for i := uint64(1); i < numBlock; i++ {
+		rots = append(rots, -int(i*pastaSeclevel))
+	}
+
+	// applyLayout
+	return append(rots, layoutRotations(messageLength, opts.Layout)...)
+}
+
+func galoisElement(params rlwe.Parameters, rot int) uint64 {
+	if rot == 0 {
+		return params.GaloisElementForRowRotation()
+	}
+
+	return params.GaloisElementForColumnRotationBy(rot)
+}
+
+// TranscipherGaloisElements returns the sorted set of galois elements the
+// transcipher circuit uses for messages of messageLength elements with opts
+// (MatmulAuto stands for the cheapest matmul strategy).
+func TranscipherGaloisElements(params rlwe.Parameters, messageLength, pastaSeclevel uint64,
+	opts TranscipherOptions) []uint64 {
+
+	return galoisElements(params, transcipherRotations(uint64(params.N()), messageLength, pastaSeclevel, opts))
+}
+
+// galoisElements returns the sorted set of galois elements of rots
+func galoisElements(params rlwe.Parameters, rots []int) []uint64 {
+	seen := make(map[uint64]bool)
+	var galEls []uint64
+	for _, rot := range rots {
+		galEl := galoisElement(params, rot)
+		if !seen[galEl] {
+			seen[galEl] = true
+			galEls = append(galEls, galEl)
+		}
+	}
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	return galEls
+}
+
+// ValidateEvaluationKeys checks evks holds every key Transcipher needs for
+// messages of messageLength elements transciphered with opts, so a missing
+// key is reported up front instead of panicking mid transcipher.
+// With MatmulAuto it's enough for the keys of one strategy to be there.
+func ValidateEvaluationKeys(params bfv.Parameters, evks *rlwe.EvaluationKeySet, messageLength,
+	pastaSeclevel uint64, opts TranscipherOptions) error {
+
+	if evks == nil || evks.RelinearizationKey == nil {
+		return errors.New("missing relinearization key")
+	}
+	if err := opts.Validate(params, messageLength); err != nil {
+		return err
+	}
+
+	if opts.Matmul != MatmulAuto {
+		return missingGaloisKeys(params, evks, messageLength, pastaSeclevel, opts)
+	}
+
+	var err error
+	for _, s := range matmulByCost {
+		opts.Matmul = s
+		if err = missingGaloisKeys(params, evks, messageLength, pastaSeclevel, opts); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no matmul strategy has all its keys, %s: %w", matmulByCost[len(matmulByCost)-1], err)
+}
+
+func missingGaloisKeys(params bfv.Parameters, evks *rlwe.EvaluationKeySet, messageLength, pastaSeclevel uint64,
+	opts TranscipherOptions) error {
+
+	seen := make(map[uint64]bool)
+	var missing []string
+	for _, rot := range transcipherRotations(uint64(params.N()), messageLength, pastaSeclevel, opts) {
+		galEl := galoisElement(params.Parameters, rot)
+		if seen[galEl] {
+			continue
+		}
+		seen[galEl] = true
+
+		if _, ok := evks.GaloisKeys[galEl]; ok {
+			continue
+		}
+		if rot == 0 {
+			missing = append(missing, fmt.Sprintf("row rotation (galois element %d)", galEl))
+		} else {
+			missing = append(missing, fmt.Sprintf("column rotation by %d (galois element %d)", rot, galEl))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing galois keys for %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package bfv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestClientServerTranscipher(t *testing.T) {
+	tc := testCases()[1]
+	messageLength := uint64(len(tc.plaintext))
+
+	// client
+	bfvParams := GenerateBfvParams(tc.modulus, tc.bfvPolyDegree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	evks := GenTranscipherKeys(bfvParams, sk, messageLength, TranscipherOptions{Matmul: MatmulAuto})
+	pastaSKCt := EncryptPastaSecretKey(tc.secretKey, bfv2.NewEncoder(bfvParams), bfv2.NewEncryptor(bfvParams, pk),
+		bfvParams)
+
+	// server
+	if _, _, _, err := NewBFVPastaServer(tc.bfvPolyDegree, tc.modulus, rlwe.NewEvaluationKeySet()); err == nil {
+		t.Errorf("expected an error without relinearization key")
+	}
+	evaluator, encoder, p, err := NewBFVPastaServer(tc.bfvPolyDegree, tc.modulus, &evks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Transcipher(tc.ciphertextExpected, pastaSKCt, PastaParams, pasta.DefaultSecLevel, encoder, evaluator,
+		p.Params)
+
+	// client
+	decrypted := DecryptPacked(res, bfv2.NewDecryptor(bfvParams, sk), bfv2.NewEncoder(bfvParams))
+	if !util.EqualSlices(decrypted, tc.plaintext) {
+		t.Errorf("decrypted a different vector")
+	}
+}
+
+func TestTranscipherGaloisElements(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<14)
+	messageLength := uint64(3*pasta.DefaultSecLevel + 1)
+
+	// row rotation, T, 15 hoisted baby steps, 7 giant steps and 3 flatten
+	// rotations
+	galEls := TranscipherGaloisElements(bfvParams.Parameters, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{})
+	if len(galEls) != 27 {
+		t.Errorf("expected 27 galois elements, got %d", len(galEls))
+	}
+	// chaining the baby steps takes a single key for them, 1 instead of 15
+	chained := TranscipherGaloisElements(bfvParams.Parameters, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{Bsgs: BsgsOptions{Chained: true}})
+	if len(chained) != 13 {
+		t.Errorf("expected 13 chained galois elements, got %d", len(chained))
+	}
+	// auto generates the keys of the cheapest strategy
+	if auto := TranscipherGaloisElements(bfvParams.Parameters, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{Matmul: MatmulAuto}); !util.EqualSlices(auto, galEls) {
+		t.Errorf("expected auto to use the bsgs galois elements")
+	}
+	// row rotation, T, the rotation by one and 3 flatten rotations
+	galEls = TranscipherGaloisElements(bfvParams.Parameters, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{Matmul: MatmulDiagonal})
+	if len(galEls) != 6 {
+		t.Errorf("expected 6 galois elements, got %d", len(galEls))
+	}
+
+	// row rotation, T, 7 baby steps, 15 giant steps and 3 flatten rotations
+	split := TranscipherOptions{Bsgs: BsgsOptions{N1: 8, N2: 16}}
+	galEls = TranscipherGaloisElements(bfvParams.Parameters, messageLength, pasta.DefaultSecLevel, split)
+	if len(galEls) != 27 {
+		t.Errorf("expected 27 galois elements for an 8 x 16 split, got %d", len(galEls))
+	}
+
+	sk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenSecretKeyNew()
+	evks := GenTranscipherKeys(bfvParams, sk, messageLength, TranscipherOptions{})
+	if len(evks.GaloisKeys) != 27 {
+		t.Errorf("expected 27 galois keys, got %d", len(evks.GaloisKeys))
+	}
+	if err := ValidateEvaluationKeys(bfvParams, &evks, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{}); err != nil {
+		t.Error(err)
+	}
+
+	delete(evks.GaloisKeys, bfvParams.GaloisElementForColumnRotationBy(-3*pasta.DefaultSecLevel))
+	err := ValidateEvaluationKeys(bfvParams, &evks, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{})
+	if err == nil || !strings.Contains(err.Error(), "column rotation by -384") {
+		t.Errorf("expected the missing rotation to be named, got %v", err)
+	}
+
+	// the keys follow the split
+	err = ValidateEvaluationKeys(bfvParams, &evks, messageLength, pasta.DefaultSecLevel, split)
+	if err == nil || !strings.Contains(err.Error(), "column rotation by -24") {
+		t.Errorf("expected the 16 x 8 keys to miss the 8 x 16 giant steps, got %v", err)
+	}
+	err = ValidateEvaluationKeys(bfvParams, &evks, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{Bsgs: BsgsOptions{N1: 10, N2: 10}})
+	if err == nil || !strings.Contains(err.Error(), "10 x 10 bsgs split") {
+		t.Errorf("expected an invalid split to be rejected, got %v", err)
+	}
+
+	diagonalEvks := GenTranscipherKeys(bfvParams, sk, messageLength, TranscipherOptions{Matmul: MatmulDiagonal})
+	err = ValidateEvaluationKeys(bfvParams, &diagonalEvks, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{})
+	if err == nil || !strings.Contains(err.Error(), "column rotation by -16") {
+		t.Errorf("expected the diagonal keys to miss the giant steps, got %v", err)
+	}
+	if err := ValidateEvaluationKeys(bfvParams, &diagonalEvks, messageLength, pasta.DefaultSecLevel,
+		TranscipherOptions{Matmul: MatmulAuto}); err != nil {
+		t.Errorf("expected auto to accept the diagonal keys, got %v", err)
+	}
+}
+
+func TestResolveMatmul(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<14)
+	sk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenSecretKeyNew()
+
+	for _, tc := range []struct {
+		keys, expected MatmulStrategy
+	}{
+		{MatmulBsgs, MatmulBsgs},
+		{MatmulDiagonal, MatmulDiagonal},
+	} {
+		evks := GenTranscipherKeys(bfvParams, sk, 1, TranscipherOptions{Matmul: tc.keys})
+		evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+		if s := resolveMatmul(MatmulAuto, BsgsOptions{}, bfvParams, evaluator); s != tc.expected {
+			t.Errorf("auto with %s keys resolved to %s, expected %s", tc.keys, s, tc.expected)
+		}
+		if s := resolveMatmul(MatmulDiagonal, BsgsOptions{}, bfvParams, evaluator); s != MatmulDiagonal {
+			t.Errorf("a concrete strategy shouldn't be resolved, got %s", s)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package bfv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Layout places a transciphered message at given slots of the first row:
+// element k of replica r lands on slot Offset + (r*n + k)*Stride, where n is
+// the message length. The zero value is the contiguous layout, a Stride or a
+// Replication of 0 count as 1.
+type Layout struct {
+	Offset      uint64
+	Stride      uint64
+	Replication uint64
+}
+
+func (l Layout) stride() uint64 {
+	if l.Stride == 0 {
+		return 1
+	}
+
+	return l.Stride
+}
+
+func (l Layout) replication() uint64 {
+	if l.Replication == 0 {
+		return 1
+	}
+
+	return l.Replication
+}
+
+func (l Layout) contiguous() bool {
+	return l.Offset == 0 && l.stride() == 1 && l.replication() == 1
+}
+
+// slot returns where element k of the first replica lands
+func (l Layout) slot(k uint64) uint64 {
+	return l.Offset + k*l.stride()
+}
+
+// ValidateLayout checks a message of messageLength elements fits in the first
+// row of a single ciphertext once laid out as opts.Layout says
+func ValidateLayout(bfvParams bfv.Parameters, messageLength uint64, opts TranscipherOptions) error {
+	l := opts.Layout
+	if l.contiguous() {
+		return nil
+	}
+	if opts.Packing != PackFirstRow {
+		return errors.New("layouts only use the first row, they can't be combined with PackBothRows")
+	}
+
+	halfslots := uint64(bfvParams.N() / 2)
+	if messageLength == 0 {
+		return nil
+	}
+	// each one bounded first, so the last slot can't overflow
+	if l.Offset >= halfslots || l.stride() > halfslots || l.replication() > halfslots || messageLength > halfslots {
+		return fmt.Errorf("layout (offset %d, stride %d, replication %d) of %d elements doesn't fit a row of %d slots",
+			l.Offset, l.stride(), l.replication(), messageLength, halfslots)
+	}
+	if last := l.slot(l.replication()*messageLength - 1); last >= halfslots {
+		return fmt.Errorf("layout needs %d slots but a row only has %d", last+1, halfslots)
+	}
+
+	return nil
+}
+
+// layoutRotations returns the column rotations applyLayout performs on a
+// message of n elements
+func layoutRotations(n uint64, l Layout) []int {
+	var rots []int
+
+	if s := l.stride(); s > 1 {
+		g, a := strideSplit(n)
+		for b := uint64(1); b < g; b++ {
+			rots = append(rots, -int(b*(s-1)))
+		}
+		for k := uint64(1); k < a; k++ {
+			rots = append(rots, -int(k*g*(s-1)))
+		}
+	}
+
+	rots = append(rots, doublingRotations(-int(n*l.stride()), l.replication())...)
+
+	if l.Offset != 0 {
+		rots = append(rots, -int(l.Offset))
+	}
+
+	return rots
+}
+
+// strideSplit splits the n elements to spread into a giant steps of g baby
+// steps each, g being the ceiling of sqrt(n)
+func strideSplit(n uint64) (g, a uint64) {
+	g = 1
+	for g*g < n {
+		g++
+	}
+
+	return g, (n + g - 1) / g
+}
+
+// applyLayout moves the n elements in the first slots of ct to the slots l
+// describes. Unless the stride is 1 only the slots of the message have to be
+// valid, otherwise ct must be zero in the rest of the first row.
+// Spreading element k to slot k*stride is a linear map with a single non
+// zero diagonal per element, evaluated babystep-giantstep so it only takes
+// one ct x pt multiplication. The replicas are added by doubling and the
+// offset is one last rotation.
+func applyLayout(ct *rlwe.Ciphertext, n uint64, l Layout, evaluator bfv.Evaluator, encoder bfv.Encoder,
+	scratch *Scratch) {
+
+	if l.contiguous() || n == 0 {
+		return
+	}
+
+	tmp := scratch.ciphertext()
+	defer scratch.release(tmp)
+
+	s := l.stride()
+	if s > 1 {
+		spread(ct, n, s, evaluator, encoder, scratch)
+	}
+
+	doublingSum(ct, -int(n*s), l.replication(), evaluator, scratch)
+
+	if l.Offset != 0 {
+		evaluator.RotateColumns(ct, -int(l.Offset), tmp)
+		ct.Copy(tmp)
+	}
+}
+
+// spread moves element k of ct to slot k*s and zeroes every other slot.
+// Element k = i*g + j is rotated by j*(s-1) in the baby step and by
+// i*g*(s-1) in the giant step, so its mask is taken i*g*(s-1) slots before
+// its target.
+func spread(ct *rlwe.Ciphertext, n, s uint64, evaluator bfv.Evaluator, encoder bfv.Encoder, scratch *Scratch) {
+	g, a := strideSplit(n)
+
+	baby := make([]*rlwe.Ciphertext, g)
+	baby[0] = ct
+	for j := uint64(1); j < g; j++ {
+		baby[j] = scratch.ciphertext()
+		evaluator.RotateColumns(ct, -int(j*(s-1)), baby[j])
+	}
+
+	acc := scratch.ciphertext()
+	inner := scratch.ciphertext()
+	tmp := scratch.ciphertext()
+	for i := uint64(0); i < a; i++ {
+		giant := i * g * (s - 1)
+
+		first := true
+		for j := uint64(0); j < g && i*g+j < n; j++ {
+			mask := scratch.slotValues()
+			mask[(i*g+j)*s-giant] = 1
+			pt := scratch.encode(mask, encoder)
+			if first {
+				evaluator.Mul(baby[j], pt, inner) // ct x pt
+				first = false
+			} else {
+				evaluator.MulThenAdd(baby[j], pt, inner) // ct x pt
+			}
+		}
+
+		if i == 0 {
+			acc.Copy(inner)
+			continue
+		}
+		evaluator.RotateColumns(inner, -int(giant), tmp)
+		evaluator.Add(acc, tmp, acc)
+	}
+	ct.Copy(acc)
+
+	scratch.release(baby[1:]...)
+	scratch.release(acc, inner, tmp)
+}
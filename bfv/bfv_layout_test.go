@@ -0,0 +1,118 @@
+package bfv
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestApplyLayout(t *testing.T) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	bfvParams := GenerateBfvParams(modulus, degree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+	halfslots := degree / 2
+
+	for _, tc := range []struct {
+		n      uint64
+		layout Layout
+	}{
+		{5, Layout{Stride: 3}},
+		{4, Layout{Replication: 3}},
+		{6, Layout{Offset: 7}},
+		{3, Layout{Offset: 2, Stride: 2, Replication: 5}},
+		{1, Layout{Replication: halfslots}}, // broadcast
+	} {
+		t.Run(fmt.Sprintf("%d %+v", tc.n, tc.layout), func(t *testing.T) {
+			var galEls []uint64
+			for _, rot := range layoutRotations(tc.n, tc.layout) {
+				galEls = append(galEls, bfvParams.GaloisElementForColumnRotationBy(rot))
+			}
+			evaluator := bfv2.NewEvaluator(bfvParams, GenEvks(bfvParams.Parameters, galEls, sk, nil))
+
+			// the second row is garbage, as in a first row packed transcipher output
+			message := RandomInputV(int(tc.n), modulus)
+			values := make([]uint64, degree)
+			copy(values, message)
+			for i := halfslots; i < degree; i++ {
+				values[i] = 3
+			}
+			pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+			encoder.Encode(values, pt)
+			ct := encryptor.EncryptNew(pt)
+
+			applyLayout(ct, tc.n, tc.layout, evaluator, encoder, NewScratch(bfvParams))
+
+			expected := make([]uint64, halfslots)
+			for r := uint64(0); r < tc.layout.replication(); r++ {
+				for k, v := range message {
+					expected[tc.layout.slot(r*tc.n+uint64(k))] = v
+				}
+			}
+			if !util.EqualSlices(DecryptPacked(Unpacked(ct, halfslots), decryptor, encoder), expected) {
+				t.Errorf("wrong layout")
+			}
+
+			packed := PackedCiphertext{Ciphertext: ct, End: tc.n, RowLength: tc.n, Layout: tc.layout}
+			if !util.EqualSlices(DecryptPacked([]PackedCiphertext{packed}, decryptor, encoder), message) {
+				t.Errorf("decoded a different message")
+			}
+		})
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<14)
+
+	for _, tc := range []struct {
+		messageLength uint64
+		opts          TranscipherOptions
+		valid         bool
+	}{
+		{1 << 14, TranscipherOptions{}, true},
+		{4096, TranscipherOptions{Layout: Layout{Stride: 2}}, true},
+		{4096, TranscipherOptions{Layout: Layout{Offset: 1, Stride: 2}}, true},
+		{4097, TranscipherOptions{Layout: Layout{Stride: 2}}, false},
+		{1, TranscipherOptions{Layout: Layout{Replication: 1 << 13}}, true},
+		{2, TranscipherOptions{Layout: Layout{Replication: 1 << 13}}, false},
+		{2, TranscipherOptions{Layout: Layout{Offset: 1}, Packing: PackBothRows}, false},
+		// the last slot would wrap around
+		{2, TranscipherOptions{Layout: Layout{Stride: 1 << 63}}, false},
+		{2, TranscipherOptions{Layout: Layout{Offset: math.MaxUint64}}, false},
+	} {
+		if err := ValidateLayout(bfvParams, tc.messageLength, tc.opts); (err == nil) != tc.valid {
+			t.Errorf("%d elements with %+v: expected valid=%t, got %v", tc.messageLength, tc.opts, tc.valid, err)
+		}
+	}
+}
+
+func TestTranscipherOptionsValidate(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<14)
+
+	for _, opts := range []TranscipherOptions{
+		{Matmul: MatmulStrategy(7)},
+		{Packing: Packing(7)},
+		{Bsgs: BsgsOptions{N1: 3, N2: 5}},
+		{Layout: Layout{Stride: 1 << 13}},
+	} {
+		if err := opts.Validate(bfvParams, 4); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+		// rejected before touching the keys
+		if _, err := TranscipherScratch(make([]uint64, 4), nil, PastaParams, pasta.DefaultSecLevel, nil, nil,
+			NewScratch(bfvParams), opts); err == nil {
+			t.Errorf("TranscipherScratch accepted %+v", opts)
+		}
+	}
+	if err := (TranscipherOptions{Matmul: MatmulAuto, Bsgs: BsgsOptions{N1: 8, N2: 16}}).Validate(bfvParams,
+		4); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package bfv
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// MatMulVec returns the encryption of matrix * v, an n x m plaintext matrix
+// times the encrypted vector v held in the first m slots of the first row of
+// ct (the rest of the row must be zero, as in Replicate). The result sits in
+// the first n slots of the first row and the rest of the ciphertext is zero,
+// so linear layers can be chained.
+//
+// It's the Halevi-Shoup diagonal method over the matrix padded to D x D, D
+// the power of two above n and m: v is replicated along the row so that
+// rotations wrap around every D slots, and the D diagonals are summed with
+// baby-step giant-step, about 2*sqrt(D) rotations. Zero diagonals are
+// skipped. The keys are given by MatMulVecGaloisElements.
+func MatMulVec(matrix [][]uint64, ct *rlwe.Ciphertext, encoder bfv.Encoder, evaluator bfv.Evaluator,
+	bfvParams bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	rows, cols, err := matrixShape(matrix)
+	if err != nil {
+		return nil, err
+	}
+	halfslots := uint64(bfvParams.N() / 2)
+	dim := linearDim(rows, cols)
+	if dim > halfslots {
+		return nil, fmt.Errorf("a %dx%d matrix doesn't fit in %d slots", rows, cols, halfslots)
+	}
+
+	scratch := NewScratch(bfvParams)
+	v := ct.CopyNew()
+	doublingSum(v, -int(dim), halfslots/dim, evaluator, scratch) // Replicate
+
+	n1, n2 := bsgsSplit(dim)
+	rot := make([]*rlwe.Ciphertext, n1)
+	rot[0] = v
+	for j := 1; j < n1; j++ {
+		rot[j] = evaluator.RotateColumnsNew(v, j)
+	}
+
+	var out *rlwe.Ciphertext
+	for k := 0; k < n2; k++ {
+		var inner *rlwe.Ciphertext
+		for j := 0; j < n1; j++ {
+			diag := scratch.slotValues()
+			if !linearDiagonal(diag, matrix, dim, uint64(k*n1+j), uint64(k*n1), halfslots) {
+				continue
+			}
+			pt := scratch.encode(diag, encoder)
+			if inner == nil {
+				inner = evaluator.MulNew(rot[j], pt)
+			} else {
+				evaluator.MulThenAdd(rot[j], pt, inner)
+			}
+		}
+		if inner == nil {
+			continue
+		}
+
+		if k > 0 {
+			evaluator.RotateColumns(inner, k*n1, inner)
+		}
+		if out == nil {
+			out = inner
+		} else {
+			evaluator.Add(out, inner, out)
+		}
+	}
+	if out == nil {
+		// the zero matrix
+		out = evaluator.MulNew(ct, uint64(0))
+	}
+
+	return out, nil
+}
+
+// MatMulVecGaloisElements returns the sorted set of galois elements MatMulVec
+// uses for a rows x cols matrix
+func MatMulVecGaloisElements(params rlwe.Parameters, rows, cols uint64) []uint64 {
+	dim := linearDim(rows, cols)
+	halfslots := uint64(params.N() / 2)
+	if dim > halfslots {
+		return nil
+	}
+
+	rots := doublingRotations(-int(dim), halfslots/dim)
+	n1, n2 := bsgsSplit(dim)
+	for j := 1; j < n1; j++ {
+		rots = append(rots, j)
+	}
+	for k := 1; k < n2; k++ {
+		rots = append(rots, k*n1)
+	}
+
+	return galoisElements(params, rots)
+}
+
+// EncryptedMatrixSlots lays out an n x m matrix for encryption so it can be
+// multiplied by EncryptedMatMulVec: row i starts at slot i*D of the first
+// row, D the power of two above m
+func EncryptedMatrixSlots(matrix [][]uint64, bfvParams bfv.Parameters) ([]uint64, error) {
+	rows, cols, err := matrixShape(matrix)
+	if err != nil {
+		return nil, err
+	}
+	dim := linearDim(1, cols)
+	if rows*dim > uint64(bfvParams.N()/2) {
+		return nil, fmt.Errorf("a %dx%d matrix doesn't fit in %d slots", rows, cols, bfvParams.N()/2)
+	}
+
+	values := make([]uint64, bfvParams.N())
+	for i, row := range matrix {
+		copy(values[uint64(i)*dim:], row)
+	}
+
+	return values, nil
+}
+
+// EncryptedMatMulVec returns the encryption of matrix * vector, an encrypted
+// rows x len(vector) matrix laid out by EncryptedMatrixSlots times a
+// plaintext vector. Entry i of the result sits at slot i*D, D the power of
+// two above len(vector), the other slots hold partial sums. It takes a
+// ct x pt multiplication and SumSlots, the keys are given by
+// EncryptedMatMulVecGaloisElements.
+func EncryptedMatMulVec(ctMatrix *rlwe.Ciphertext, rows uint64, vector []uint64, encoder bfv.Encoder,
+	evaluator bfv.Evaluator, bfvParams bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	if rows == 0 || len(vector) == 0 {
+		return nil, errors.New("empty matrix")
+	}
+	dim := linearDim(1, uint64(len(vector)))
+	if rows*dim > uint64(bfvParams.N()/2) {
+		return nil, fmt.Errorf("a %dx%d matrix doesn't fit in %d slots", rows, len(vector), bfvParams.N()/2)
+	}
+
+	scratch := NewScratch(bfvParams)
+	values := scratch.slotValues()
+	for i := uint64(0); i < rows; i++ {
+		copy(values[i*dim:], vector)
+	}
+
+	out := evaluator.MulNew(ctMatrix, scratch.encode(values, encoder))
+	doublingSum(out, 1, dim, evaluator, scratch)
+
+	return out, nil
+}
+
+// EncryptedMatMulVecGaloisElements returns the sorted set of galois elements
+// EncryptedMatMulVec uses for vectors of cols elements
+func EncryptedMatMulVecGaloisElements(params rlwe.Parameters, cols uint64) []uint64 {
+	return SumSlotsGaloisElements(params, linearDim(1, cols))
+}
+
+// matrixShape returns the dimensions of matrix, every row must be as long
+func matrixShape(matrix [][]uint64) (rows, cols uint64, err error) {
+	if len(matrix) == 0 || len(matrix[0]) == 0 {
+		return 0, 0, errors.New("empty matrix")
+	}
+	for i, row := range matrix {
+		if len(row) != len(matrix[0]) {
+			return 0, 0, fmt.Errorf("row %d has %d elements, expected %d", i, len(row), len(matrix[0]))
+		}
+	}
+
+	return uint64(len(matrix)), uint64(len(matrix[0])), nil
+}
+
+// linearDim returns the power of two above rows and cols
+func linearDim(rows, cols uint64) uint64 {
+	dim := rows
+	if cols > dim {
+		dim = cols
+	}
+
+	return 1 << bits.Len64(dim-1)
+}
+
+// bsgsSplit splits dim = n1*n2 with n1 the power of two about sqrt(dim)
+func bsgsSplit(dim uint64) (n1, n2 int) {
+	n1 = 1 << (bits.Len64(dim) / 2)
+
+	return n1, int(dim) / n1
+}
+
+// linearDiagonal writes into diag the i-th diagonal of matrix padded to
+// dim x dim, diag[s] = matrix[s][s+i mod dim], pre-rotated right by giant so
+// the giant step rotation can be applied after the inner sum. It returns
+// false for a zero diagonal.
+func linearDiagonal(diag []uint64, matrix [][]uint64, dim, i, giant, halfslots uint64) bool {
+	nonZero := false
+	for s, row := range matrix {
+		col := (uint64(s) + i) % dim
+		if col >= uint64(len(row)) || row[col] == 0 {
+			continue
+		}
+		diag[(uint64(s)+giant)%halfslots] = row[col]
+		nonZero = true
+	}
+
+	return nonZero
+}
@@ -0,0 +1,89 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestMatMulVec(t *testing.T) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	bfvParams := GenerateBfvParams(modulus, degree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+
+	shapes := [][2]uint64{{5, 11}, {13, 3}, {16, 16}, {1, 1}}
+	var galEls []uint64
+	for _, shape := range shapes {
+		galEls = append(galEls, MatMulVecGaloisElements(bfvParams.Parameters, shape[0], shape[1])...)
+		galEls = append(galEls, EncryptedMatMulVecGaloisElements(bfvParams.Parameters, shape[1])...)
+	}
+	evks := GenSlotKeys(bfvParams, sk, galEls)
+	evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+
+	encrypt := func(values []uint64) *rlwe.Ciphertext {
+		pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+		encoder.Encode(values, pt)
+		return encryptor.EncryptNew(pt)
+	}
+	decrypt := func(ct *rlwe.Ciphertext) []uint64 {
+		return encoder.DecodeUintNew(decryptor.DecryptNew(ct))
+	}
+
+	for _, shape := range shapes {
+		rows, cols := shape[0], shape[1]
+		matrix := make([][]uint64, rows)
+		for i := range matrix {
+			matrix[i] = RandomInputV(int(cols), modulus)
+		}
+		matrix[0][0] = 0 // some zero diagonals
+		v := RandomInputV(int(cols), modulus)
+
+		expected := make([]uint64, rows)
+		for i, row := range matrix {
+			for j, m := range row {
+				expected[i] = (expected[i] + mulMod(m, v[j], modulus)) % modulus
+			}
+		}
+
+		// plaintext matrix, encrypted vector
+		res, err := MatMulVec(matrix, encrypt(v), encoder, evaluator, bfvParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted := decrypt(res)
+		if !util.EqualSlices(decrypted[:rows], expected) {
+			t.Errorf("%dx%d: got %v, expected %v", rows, cols, decrypted[:rows], expected)
+		}
+		if !util.EqualSlices(decrypted[rows:], make([]uint64, degree-rows)) {
+			t.Errorf("%dx%d: the rest of the ciphertext isn't zero", rows, cols)
+		}
+
+		// encrypted matrix, plaintext vector
+		values, err := EncryptedMatrixSlots(matrix, bfvParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err = EncryptedMatMulVec(encrypt(values), rows, v, encoder, evaluator, bfvParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted = decrypt(res)
+		dim := linearDim(1, cols)
+		for i := uint64(0); i < rows; i++ {
+			if decrypted[i*dim] != expected[i] {
+				t.Errorf("%dx%d encrypted matrix: row %d got %d, expected %d", rows, cols, i,
+					decrypted[i*dim], expected[i])
+			}
+		}
+	}
+
+	if _, err := MatMulVec([][]uint64{{1, 2}, {3}}, encrypt([]uint64{1}), encoder, evaluator,
+		bfvParams); err == nil {
+		t.Errorf("expected an error for a ragged matrix")
+	}
+}
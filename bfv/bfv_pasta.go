@@ -3,16 +3,22 @@ package bfv
 import (
 	"fmt"
 	"github.com/fedejinich/hhego/pasta"
-	"github.com/fedejinich/hhego/util"
 	"github.com/tuneinsight/lattigo/v4/bfv"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
+// AddRc returns state + rc, see addRcInPlace
 func AddRc(state *rlwe.Ciphertext, rc []uint64, encoder bfv.Encoder, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
-	roundConstants := bfv.NewPlaintext(bfvParams, bfvParams.MaxLevel())
-	encoder.Encode(rc, roundConstants)
+	out := state.CopyNew()
+	addRcInPlace(out, rc, NewScratch(bfvParams), encoder, evaluator)
 
-	return evaluator.AddNew(state, roundConstants) // ct + pt
+	return out
+}
+
+func addRcInPlace(state *rlwe.Ciphertext, rc []uint64, scratch *Scratch, encoder bfv.Encoder,
+	evaluator bfv.Evaluator) {
+
+	evaluator.Add(state, scratch.encode(rc, encoder), state) // ct + pt
 }
 
 func Mix(state *rlwe.Ciphertext, evaluator bfv.Evaluator, encoder bfv.Encoder) *rlwe.Ciphertext {
@@ -23,6 +29,14 @@ func Mix(state *rlwe.Ciphertext, evaluator bfv.Evaluator, encoder bfv.Encoder) *
 	return evaluator.AddNew(stateOriginal, tmp)
 }
 
+func mixInPlace(state *rlwe.Ciphertext, scratch *Scratch, evaluator bfv.Evaluator) {
+	tmp := scratch.ciphertext()
+	evaluator.RotateRows(state, tmp)
+	evaluator.Add(tmp, state, tmp)
+	evaluator.Add(state, tmp, state)
+	scratch.release(tmp)
+}
+
 func SboxCube(state *rlwe.Ciphertext, evaluator bfv.Evaluator) *rlwe.Ciphertext {
 	s := state.CopyNew()
 	state = evaluator.MulNew(state, state) // ^ 2 ct x ct -> relinearization
@@ -33,49 +47,237 @@ func SboxCube(state *rlwe.Ciphertext, evaluator bfv.Evaluator) *rlwe.Ciphertext
 	return state
 }
 
+// sboxCubeInPlace cubes every slot of state. Both products are relinearized
+// right away, there is nowhere to delay them to: lattigo v4 can't multiply a
+// degree 2 ciphertext (nor relinearize a degree 3 one) and the cube feeds the
+// rotations of the final matmul, which take degree 1 ciphertexts too.
+func sboxCubeInPlace(state *rlwe.Ciphertext, scratch *Scratch, evaluator bfv.Evaluator) {
+	tensor := scratch.tensorCiphertext()
+	square := scratch.ciphertext()
+
+	evaluator.Mul(state, state, tensor) // ^ 2 ct x ct -> relinearization
+	evaluator.Relinearize(tensor, square)
+	evaluator.Mul(square, state, tensor) // ^ 3  ct x ct -> relinearization
+	evaluator.Relinearize(tensor, state)
+
+	scratch.release(square)
+}
+
+// SboxFeistel returns the feistel sbox applied to state, see sboxFeistelInPlace
 func SboxFeistel(state *rlwe.Ciphertext, halfslots uint64, evaluator bfv.Evaluator,
 	encoder bfv.Encoder, bfvParams bfv.Parameters) *rlwe.Ciphertext {
-	originalState := state.CopyNew()
+	out := state.CopyNew()
+	sboxFeistelInPlace(out, 1, NewScratch(bfvParams), encoder, evaluator)
+
+	return out
+}
 
-	// rotate state
-	stateRot := evaluator.RotateColumnsNew(state, -1)
+// sboxFeistelInPlace applies the feistel sbox to the first instances of state,
+// the square is relinearized right away since the next matmul rotates it
+func sboxFeistelInPlace(state *rlwe.Ciphertext, instances int, scratch *Scratch, encoder bfv.Encoder,
+	evaluator bfv.Evaluator) {
+	tensor := scratch.tensorCiphertext()
+	stateRot := scratch.ciphertext()
 
-	// mask rotate state
-	maskVec := make([]uint64, uint64(pasta.T)+halfslots)
-	for i := range maskVec {
-		maskVec[i] = 1
-	}
-	maskVec[0] = 0
-	maskVec[halfslots] = 0
-	for i := uint64(pasta.T); i < halfslots; i++ {
-		maskVec[i] = 0
-	}
-	mask := bfv.NewPlaintext(bfvParams, bfvParams.MaxLevel())
-	encoder.Encode(maskVec, mask)
-	stateRot = evaluator.MulNew(stateRot, mask) // ct x pt
+	// rotate and mask state
+	evaluator.RotateColumns(state, -1, stateRot)
+	evaluator.Mul(stateRot, scratch.sboxFeistelMask(instances, encoder), stateRot) // ct x pt
 
 	// square
-	state = evaluator.MulNew(stateRot, stateRot)
-	state = evaluator.RelinearizeNew(state) // ct x ct -> relinearization
+	evaluator.Mul(stateRot, stateRot, tensor)
+	evaluator.Relinearize(tensor, stateRot) // ct x ct -> relinearization
 
 	// add
-	result := evaluator.AddNew(originalState, state)
+	evaluator.Add(state, stateRot, state)
 
-	return result
+	scratch.release(stateRot)
 }
 
+// MatmulStrategy selects how Matmul multiplies the state by the pasta
+// matrices, each strategy needs its own set of galois keys (see
+// TranscipherGaloisElements)
+type MatmulStrategy int
+
+const (
+	// MatmulBsgs is babystep-giantstep over the N1 x N2 split of
+	// TranscipherOptions.Bsgs, N1-1 baby steps and N2-1 giant steps
+	MatmulBsgs MatmulStrategy = iota
+	// MatmulDiagonal rotates the state by one pasta.T-1 times, it's the
+	// slowest but only needs a single rotation key
+	MatmulDiagonal
+	// MatmulAuto picks the cheapest strategy the evaluator has keys for
+	MatmulAuto
+)
+
+// BsgsOptions tunes MatmulBsgs, the zero value splits the pasta.T diagonals
+// as BsgsN1 x BsgsN2 and hoists the baby steps. Matmul, the galois keys (TranscipherGaloisElements) and
+// ValidateEvaluationKeys all follow it, so the keys must be generated with
+// the same options the server transciphers with.
+type BsgsOptions struct {
+	// N1 baby steps by N2 giant steps, N1*N2 must be pasta.T
+	N1, N2 int
+	// Chained reaches the baby steps chaining N1-1 rotations by one instead
+	// of decomposing the state once and rotating it straight to each of them.
+	// It only needs the key of the rotation by one, N1-2 fewer keys to ship,
+	// but decomposes N1-2 more times per matmul: with 2^14 parameters the
+	// galois keys go from ~126 to ~53 MB and the matmul is ~20% slower (see
+	// BenchmarkMatmul), with 2^16 ones they go from ~3.5 to ~1.5 GB.
+	Chained bool
+}
+
+// split returns N1 and N2, or BsgsN1 and BsgsN2 if both are unset
+func (o BsgsOptions) split() (int, int) {
+	if o.N1 == 0 && o.N2 == 0 {
+		return BsgsN1, BsgsN2
+	}
+
+	return o.N1, o.N2
+}
+
+func (o BsgsOptions) validate() error {
+	if n1, n2 := o.split(); n1 < 1 || n2 < 1 || n1*n2 != pasta.T {
+		return fmt.Errorf("a %d x %d bsgs split doesn't cover the %d pasta diagonals", n1, n2, pasta.T)
+	}
+
+	return nil
+}
+
+// matmulByCost lists the concrete strategies from the cheapest to evaluate
+var matmulByCost = []MatmulStrategy{MatmulBsgs, MatmulDiagonal}
+
+func (s MatmulStrategy) String() string {
+	switch s {
+	case MatmulBsgs:
+		return "bsgs"
+	case MatmulDiagonal:
+		return "diagonal"
+	case MatmulAuto:
+		return "auto"
+	}
+
+	return fmt.Sprintf("MatmulStrategy(%d)", int(s))
+}
+
+// matmulRotations returns the column rotations Matmul performs with strategy,
+// MatmulAuto stands for the cheapest one
+func matmulRotations(slots uint64, strategy MatmulStrategy, bsgs BsgsOptions) []int {
+	var rots []int
+
+	// non-full-packed rotation preparation
+	if slots/2 != pasta.T {
+		rots = append(rots, pasta.T)
+	}
+
+	if strategy == MatmulDiagonal {
+		return append(rots, -1)
+	}
+
+	if err := bsgs.validate(); err != nil {
+		panic(err)
+	}
+	n1, n2 := bsgs.split()
+
+	// baby steps
+	if bsgs.Chained {
+		if n1 > 1 {
+			rots = append(rots, -1)
+		}
+	} else {
+		for j := 1; j < n1; j++ {
+			rots = append(rots, -j)
+		}
+	}
+
+	// giant steps
+	for k := 1; k < n2; k++ {
+		rots = append(rots, -k*n1)
+	}
+
+	return rots
+}
+
+// resolveMatmul turns MatmulAuto into the cheapest strategy whose galois keys
+// the evaluator holds, other strategies are returned as they are
+func resolveMatmul(strategy MatmulStrategy, bsgs BsgsOptions, params bfv.Parameters,
+	evaluator bfv.Evaluator) MatmulStrategy {
+	if strategy != MatmulAuto {
+		return strategy
+	}
+
+	eval := evaluator.GetRLWEEvaluator()
+	for _, s := range matmulByCost {
+		available := true
+		for _, rot := range matmulRotations(uint64(params.N()), s, bsgs) {
+			if _, err := eval.CheckAndGetGaloisKey(params.GaloisElementForColumnRotationBy(rot)); err != nil {
+				available = false
+				break
+			}
+		}
+		if available {
+			return s
+		}
+	}
+
+	// nothing fits, let the cheapest one report the missing key
+	return matmulByCost[0]
+}
+
+// Matmul returns the pasta matrix multiplication of both branches of state,
+// see matmulInPlace
 func Matmul(state *rlwe.Ciphertext, mat1, mat2 [][]uint64, slots, halfslots uint64, evaluator bfv.Evaluator,
 	encoder bfv.Encoder, bfvParams bfv.Parameters, useBsGs bool) *rlwe.Ciphertext {
-	if useBsGs {
-		return babyStepGiantStep(state, mat1, mat2, slots, encoder, bfvParams, evaluator)
+	strategy := MatmulBsgs
+	if !useBsGs {
+		strategy = MatmulDiagonal
+	}
+
+	out := state.CopyNew()
+	matmulInPlace(out, []pastaMatrices{{mat1, mat2}}, strategy, BsgsOptions{}, NewScratch(bfvParams), encoder,
+		evaluator)
+
+	return out
+}
+
+// pastaMatrices are the matrices of both branches of one pasta instance,
+// instance m of a state sits at slot m*BatchWindow of each row
+type pastaMatrices struct {
+	mat1, mat2 [][]uint64
+}
+
+// matmulInPlace multiplies each instance of state by its pasta matrices with
+// strategy, which must be already resolved (see resolveMatmul). Instances
+// without matrices are zeroed.
+func matmulInPlace(state *rlwe.Ciphertext, mats []pastaMatrices, strategy MatmulStrategy, bsgs BsgsOptions,
+	scratch *Scratch, encoder bfv.Encoder, evaluator bfv.Evaluator) {
+	switch strategy {
+	case MatmulBsgs:
+		babyStepGiantStep(state, mats, bsgs, scratch, encoder, evaluator)
+	case MatmulDiagonal:
+		diagonal(state, mats, scratch, encoder, evaluator)
+	default:
+		panic(fmt.Sprintf("unresolved matmul strategy %s", strategy))
+	}
+}
+
+// prepareMatmul copies each branch right before itself so rotations within
+// the pasta.T slots of an instance wrap around as in a full-packed row, the
+// copy of instance m lands in the free half of the window of instance m-1
+func prepareMatmul(state *rlwe.Ciphertext, scratch *Scratch, evaluator bfv.Evaluator) {
+	if scratch.params.N()/2 == pasta.T {
+		return
 	}
 
-	return diagonal(*state, mat1, mat2, int(slots), int(halfslots), evaluator, encoder, bfvParams)
+	stateRot := scratch.ciphertext()
+	evaluator.RotateColumns(state, pasta.T, stateRot)
+	evaluator.Add(state, stateRot, state)
+	scratch.release(stateRot)
 }
 
-func babyStepGiantStep(state *rlwe.Ciphertext, mat1 [][]uint64, mat2 [][]uint64, slots uint64, encoder bfv.Encoder,
-	params bfv.Parameters, evaluator bfv.Evaluator) *rlwe.Ciphertext {
+func babyStepGiantStep(state *rlwe.Ciphertext, mats []pastaMatrices, bsgs BsgsOptions, scratch *Scratch,
+	encoder bfv.Encoder, evaluator bfv.Evaluator) {
 
+	params := scratch.params
+	slots := uint64(params.N())
 	halfslots := slots / 2
 	matrixDim := uint64(pasta.T)
 
@@ -83,135 +285,186 @@ func babyStepGiantStep(state *rlwe.Ciphertext, mat1 [][]uint64, mat2 [][]uint64,
 		panic("too little slots for matmul implementation!")
 	}
 
-	if BsgsN1*BsgsN2 != matrixDim {
+	n1, n2 := bsgs.split()
+	if uint64(n1*n2) != matrixDim {
 		panic("wrong bsgs parameters")
 	}
 
-	// diagonal method preparation
-	matrix := make([]*rlwe.Plaintext, matrixDim)
-	for i := uint64(0); i < matrixDim; i++ {
-		diag := make([]uint64, halfslots+matrixDim)
-		tmp := make([]uint64, matrixDim)
-		k := i / BsgsN1
-		for j := uint64(0); j < matrixDim; j++ {
-			diag[j] = mat1[j][(j+matrixDim-i)%matrixDim]
-			tmp[j] = mat2[j][(j+matrixDim-i)%matrixDim]
-		}
-
-		// rotate:
-		if k > 0 {
-			diag = util.Rotate(diag, 0, k*BsgsN1, matrixDim) // only rotate filled elements
-			tmp = util.Rotate(tmp, 0, k*BsgsN1, matrixDim)
-		}
-
-		if halfslots != pasta.T {
-
-			diag = resize(diag, halfslots)
+	prepareMatmul(state, scratch, evaluator)
 
-			tmp = resize(tmp, halfslots)
+	var rot []*rlwe.Ciphertext
+	if bsgs.Chained {
+		rot = rotateChained(state, n1, scratch, evaluator)
+	} else {
+		rot = rotateHoisted(state, n1, scratch, evaluator)
+	}
 
-			for m := uint64(0); m < k*BsgsN1; m++ {
-				indexSrc := pasta.T - 1 - m
-				indexDest := halfslots - 1 - m
-				diag[indexDest] = diag[indexSrc]
-				diag[indexSrc] = 0
-				tmp[indexDest] = tmp[indexSrc]
-				tmp[indexSrc] = 0
+	// bsgs, each inner sum is sent to the giant step accumulator as soon as
+	// it's ready
+	gs := newGiantSteps(n1, scratch, evaluator)
+	innerSum := scratch.ciphertext()
+	for k := 0; k < n2; k++ {
+		for j := 0; j < n1; j++ {
+			diag := scratch.slotValues()
+			bsgsDiagonal(diag, mats, uint64(k*n1+j), uint64(k*n1), halfslots)
+			pt := scratch.encode(diag, encoder)
+			if j == 0 {
+				evaluator.Mul(rot[j], pt, innerSum)
+			} else {
+				evaluator.MulThenAdd(rot[j], pt, innerSum)
 			}
 		}
-		// combine both diags
+		gs.add(k, innerSum)
+	}
 
-		diag = resize(diag, slots)
+	// rot[0] is state itself, it's free to hold the result now
+	scratch.release(rot[1:]...)
+	scratch.release(innerSum)
+	gs.sum(state)
+}
 
-		for j := halfslots; j < slots; j++ {
-			diag[j] = tmp[j-halfslots]
+// bsgsDiagonal writes the i-th diagonal of the matrices of each instance, one
+// on each row of diag, pre-rotated by giant so the giant step rotation can be
+// applied after the inner sum
+func bsgsDiagonal(diag []uint64, mats []pastaMatrices, i, giant, halfslots uint64) {
+	matrixDim := uint64(pasta.T)
+	for m, mat := range mats {
+		offset := uint64(m) * BatchWindow
+		for j := uint64(0); j < matrixDim; j++ {
+			dst := (offset + j + halfslots - giant) % halfslots
+			diag[dst] = mat.mat1[j][(j+matrixDim-i)%matrixDim]
+			diag[halfslots+dst] = mat.mat2[j][(j+matrixDim-i)%matrixDim]
 		}
-
-		row := bfv.NewPlaintext(params, params.MaxLevel())
-		encoder.Encode(diag, row)
-		matrix[i] = row
 	}
+}
 
-	// prepare for non-full-packed rotations
-	if halfslots != pasta.T {
-		stateRot := evaluator.RotateColumnsNew(state, pasta.T)
-		state = evaluator.AddNew(state, stateRot)
+// rotateChained returns state rotated by 0, -1, ..., -(n-1), each one is the
+// previous rotated by -1 so a single galois key is needed
+func rotateChained(state *rlwe.Ciphertext, n int, scratch *Scratch, evaluator bfv.Evaluator) []*rlwe.Ciphertext {
+	rot := make([]*rlwe.Ciphertext, n)
+	rot[0] = state
+	for j := 1; j < n; j++ {
+		rot[j] = scratch.ciphertext()
+		evaluator.RotateColumns(rot[j-1], -1, rot[j])
 	}
-	rot := make([]*rlwe.Ciphertext, BsgsN1)
+
+	return rot
+}
+
+// rotateHoisted returns state rotated by 0, -1, ..., -(n-1). The input is
+// decomposed once and the decomposition is shared by every rotation.
+func rotateHoisted(state *rlwe.Ciphertext, n int, scratch *Scratch, evaluator bfv.Evaluator) []*rlwe.Ciphertext {
+	params := scratch.params
+	levelQ, levelP := state.Level(), params.MaxLevelP()
+
+	eval := evaluator.GetRLWEEvaluator()
+	decompQP := eval.BuffDecompQP
+	eval.DecomposeNTT(levelQ, levelP, levelP+1, state.Value[1], state.IsNTT, decompQP)
+
+	rot := make([]*rlwe.Ciphertext, n)
 	rot[0] = state
-	for j := 1; j < BsgsN1; j++ {
-		rot[j] = evaluator.RotateColumnsNew(rot[j-1], -1)
-	}
-	// bsgs
-	var innerSum, outerSum, temp *rlwe.Ciphertext
-	for k := 0; k < BsgsN2; k++ {
-		innerSum = evaluator.MulNew(rot[0], matrix[k*BsgsN1])
-		for j := 1; j < BsgsN1; j++ {
-			temp = evaluator.MulNew(rot[j], matrix[k*BsgsN1+j])
-			innerSum = evaluator.AddNew(innerSum, temp)
-		}
-		if k == 0 {
-			outerSum = innerSum
-		} else {
-			innerSum = evaluator.RotateColumnsNew(innerSum, -k*BsgsN1)
-			outerSum = evaluator.AddNew(outerSum, innerSum)
-		}
+	for j := 1; j < n; j++ {
+		rot[j] = scratch.ciphertext()
+		evaluator.AutomorphismHoisted(levelQ, state, decompQP, params.GaloisElementForColumnRotationBy(-j), rot[j])
 	}
 
-	return outerSum
+	return rot
+}
+
+// giantSteps accumulates inner sums rotated by -k*n1. Rotated terms are
+// key switched lazily: they're accumulated modulo QP and divided by P once in
+// sum, instead of once per rotation.
+type giantSteps struct {
+	n1        int
+	scratch   *Scratch
+	evaluator bfv.Evaluator
+
+	first    *rlwe.Ciphertext // k = 0 isn't rotated
+	acc, tmp *rlwe.OperandQP
+	rotated  bool
 }
 
-func resize(init []uint64, newSize uint64) []uint64 {
-	if newSize == uint64(len(init)) {
-		return init
+func newGiantSteps(n1 int, scratch *Scratch, evaluator bfv.Evaluator) *giantSteps {
+	acc, tmp := scratch.operandsQP()
+
+	return &giantSteps{n1: n1, scratch: scratch, evaluator: evaluator, acc: acc, tmp: tmp}
+}
+
+func (g *giantSteps) add(k int, innerSum *rlwe.Ciphertext) {
+	if k == 0 {
+		g.first = g.scratch.ciphertext()
+		g.first.Copy(innerSum)
+		return
 	}
 
-	if newSize < uint64(len(init)) {
-		return init[0:newSize]
+	params := g.scratch.params
+	levelQ, levelP := innerSum.Level(), params.MaxLevelP()
+
+	eval := g.evaluator.GetRLWEEvaluator()
+	eval.DecomposeNTT(levelQ, levelP, levelP+1, innerSum.Value[1], innerSum.IsNTT, eval.BuffDecompQP)
+	galEl := params.GaloisElementForColumnRotationBy(-k * g.n1)
+	if !g.rotated {
+		eval.AutomorphismHoistedLazy(levelQ, innerSum, eval.BuffDecompQP, galEl, g.acc)
+		g.rotated = true
+		return
 	}
+	eval.AutomorphismHoistedLazy(levelQ, innerSum, eval.BuffDecompQP, galEl, g.tmp)
+	ringQP := params.RingQP().AtLevel(levelQ, levelP)
+	ringQP.Add(g.acc.Value[0], g.tmp.Value[0], g.acc.Value[0])
+	ringQP.Add(g.acc.Value[1], g.tmp.Value[1], g.acc.Value[1])
+}
 
-	newSlice := make([]uint64, newSize) // new
-	for i := 0; i < len(init); i++ {
-		newSlice[i] = init[i]
+// sum writes the sum of every added inner sum into out
+func (g *giantSteps) sum(out *rlwe.Ciphertext) {
+	defer g.scratch.release(g.first)
+
+	if !g.rotated {
+		out.Copy(g.first)
+		return
 	}
 
-	return newSlice
+	eval := g.evaluator.GetRLWEEvaluator()
+	out.IsNTT = g.first.IsNTT
+	eval.ModDown(g.first.Level(), g.scratch.params.MaxLevelP(), g.acc, out)
+	out.MetaData = g.first.MetaData
+	g.evaluator.Add(out, g.first, out)
 }
 
-func diagonal(state rlwe.Ciphertext, mat1, mat2 [][]uint64, slots, halfslots int, evaluator bfv.Evaluator,
-	encoder bfv.Encoder, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+func diagonal(state *rlwe.Ciphertext, mats []pastaMatrices, scratch *Scratch, encoder bfv.Encoder,
+	evaluator bfv.Evaluator) {
 
+	slots := scratch.params.N()
+	halfslots := slots / 2
 	matrixDim := pasta.T
 
 	if matrixDim*2 != slots && matrixDim*4 > slots {
-		fmt.Errorf("too little slots for matmul implementation!")
+		panic("too little slots for matmul implementation!")
 	}
 
 	// non-full-packed rotation preparation
-	if halfslots != matrixDim {
-		stateRot := evaluator.RotateColumnsNew(&state, matrixDim)
-		state = *evaluator.AddNew(&state, stateRot)
-	}
+	prepareMatmul(state, scratch, evaluator)
 
-	// diagonal method preperation:
-	matrix := make([]rlwe.Plaintext, matrixDim)
+	rot := scratch.ciphertext()
+	next := scratch.ciphertext()
+	rot.Copy(state)
 	for i := 0; i < matrixDim; i++ {
-		diag := make([]uint64, matrixDim+halfslots)
-		for j := 0; j < matrixDim; j++ {
-			diag[j] = mat1[j][(j+matrixDim-i)%matrixDim]
-			diag[j+halfslots] = mat2[j][(j+matrixDim-i)%matrixDim]
+		diag := scratch.slotValues()
+		for m, mat := range mats {
+			offset := m * BatchWindow
+			for j := 0; j < matrixDim; j++ {
+				diag[offset+j] = mat.mat1[j][(j+matrixDim-i)%matrixDim]
+				diag[offset+j+halfslots] = mat.mat2[j][(j+matrixDim-i)%matrixDim]
+			}
 		}
-		row := bfv.NewPlaintext(bfvParams, bfvParams.MaxLevel())
-		encoder.Encode(diag, row)
-		matrix[i] = *row
-	}
+		pt := scratch.encode(diag, encoder)
 
-	sum := evaluator.MulNew(&state, &matrix[0]) // ciphertext X plaintext, no need relin
-	for i := 1; i < matrixDim; i++ {
-		state = *evaluator.RotateColumnsNew(&state, -1)
-		tmp := evaluator.MulNew(&state, &matrix[i]) // ciphertext X plaintext, no need relin
-		sum = evaluator.AddNew(sum, tmp)
+		if i == 0 {
+			evaluator.Mul(rot, pt, state) // ciphertext X plaintext, no need relin
+			continue
+		}
+		evaluator.RotateColumns(rot, -1, next)
+		rot, next = next, rot
+		evaluator.MulThenAdd(rot, pt, state) // ciphertext X plaintext, no need relin
 	}
-
-	return sum
+	scratch.release(rot, next)
 }
@@ -0,0 +1,204 @@
+package bfv
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// EvaluatePoly returns coeffs[0] + coeffs[1]*ct + ... + coeffs[d]*ct^d on
+// every slot of ct, the coefficients are taken mod T. It uses baby-step
+// giant-step: the powers ct^1..ct^(k-1) (k about sqrt(d)) evaluate chunks of
+// k coefficients with scalar multiplications only, and the chunks are put
+// together with the giant powers ct^k, ct^2k, ct^4k... so it takes about
+// 2*sqrt(d) relinearized multiplications and PolyDepth(d) levels. It only
+// needs the relinearization key.
+func EvaluatePoly(ct *rlwe.Ciphertext, coeffs []uint64, evaluator bfv.Evaluator,
+	bfvParams bfv.Parameters) (*rlwe.Ciphertext, error) {
+
+	if len(coeffs) == 0 {
+		return nil, errors.New("the polynomial has no coefficients")
+	}
+
+	t := bfvParams.T()
+	reduced := make([]uint64, len(coeffs))
+	d := 0
+	for i, c := range coeffs {
+		reduced[i] = c % t
+		if reduced[i] != 0 {
+			d = i
+		}
+	}
+	reduced = reduced[:d+1]
+
+	k := babySteps(uint64(d))
+	chunks := d/k + 1
+
+	// baby steps, ct^k too if there are giant steps
+	top := d
+	if chunks > 1 {
+		top = k
+	}
+	powers := make([]*rlwe.Ciphertext, top+1)
+	if top > 0 {
+		powers[1] = ct
+	}
+	for i := 2; i <= top; i++ {
+		hi := 1 << (bits.Len(uint(i)) - 1)
+		if hi == i {
+			powers[i] = evaluator.MulRelinNew(powers[i/2], powers[i/2])
+		} else {
+			powers[i] = evaluator.MulRelinNew(powers[hi], powers[i-hi])
+		}
+	}
+
+	// giant steps ct^k, ct^2k, ct^4k...
+	giants := make([]*rlwe.Ciphertext, bits.Len(uint(chunks-1)))
+	for j := range giants {
+		if j == 0 {
+			giants[j] = powers[k]
+		} else {
+			giants[j] = evaluator.MulRelinNew(giants[j-1], giants[j-1])
+		}
+	}
+
+	values := make([]polyChunk, chunks)
+	for j := range values {
+		end := (j + 1) * k
+		if end > len(reduced) {
+			end = len(reduced)
+		}
+		values[j] = evaluateChunk(reduced[j*k:end], powers, evaluator)
+	}
+
+	out := combineChunks(values, giants, evaluator)
+	if out.ct == nil {
+		// a constant polynomial
+		out.ct = evaluator.MulNew(ct, uint64(0))
+		evaluator.Add(out.ct, out.constant, out.ct)
+	}
+
+	return out.ct, nil
+}
+
+// PolyDepth returns the multiplicative depth EvaluatePoly takes for a
+// polynomial of degree d, at most one more than log2(d) (less for a sparse
+// polynomial)
+func PolyDepth(d uint64) int {
+	if d < 2 {
+		return 0
+	}
+
+	k := uint64(babySteps(d))
+	logK := bits.Len64(k) - 1
+
+	// -1 for a constant chunk
+	var combine func(lo, n uint64) int
+	combine = func(lo, n uint64) int {
+		if n == 1 {
+			if h := minUint64(k-1, d-lo*k); h > 0 {
+				return powerDepth(h)
+			}
+			return -1
+		}
+		j := bits.Len64(n-1) - 1
+		m := uint64(1) << j
+		depth := logK + j
+		if upper := combine(lo+m, n-m); upper >= 0 {
+			if upper > depth {
+				depth = upper
+			}
+			depth++
+		}
+		if lower := combine(lo, m); lower > depth {
+			return lower
+		}
+
+		return depth
+	}
+
+	return combine(0, d/k+1)
+}
+
+// polyChunk is a partial sum of EvaluatePoly, ct is nil while it is only
+// a constant, multiplying a constant by a power of ct then takes no level
+type polyChunk struct {
+	ct       *rlwe.Ciphertext
+	constant uint64
+}
+
+// evaluateChunk returns coeffs[0] + coeffs[1]*ct + ... with the baby step
+// powers
+func evaluateChunk(coeffs []uint64, powers []*rlwe.Ciphertext, evaluator bfv.Evaluator) polyChunk {
+	var out *rlwe.Ciphertext
+	for i := 1; i < len(coeffs); i++ {
+		if coeffs[i] == 0 {
+			continue
+		}
+		if out == nil {
+			out = evaluator.MulNew(powers[i], coeffs[i])
+		} else {
+			evaluator.MulThenAdd(powers[i], coeffs[i], out)
+		}
+	}
+	if out == nil {
+		return polyChunk{constant: coeffs[0]}
+	}
+	evaluator.Add(out, coeffs[0], out)
+
+	return polyChunk{ct: out}
+}
+
+// combineChunks returns sum(chunks[j] * ct^(j*k)) splitting the chunks at the
+// largest power of two m below their number: lower + ct^(m*k) * upper, where
+// giants[j] holds ct^(2^j*k)
+func combineChunks(chunks []polyChunk, giants []*rlwe.Ciphertext, evaluator bfv.Evaluator) polyChunk {
+	if len(chunks) == 1 {
+		return chunks[0]
+	}
+
+	j := bits.Len(uint(len(chunks)-1)) - 1
+	m := 1 << j
+	lower := combineChunks(chunks[:m], giants, evaluator)
+	upper := combineChunks(chunks[m:], giants, evaluator)
+
+	var out *rlwe.Ciphertext
+	switch {
+	case upper.ct != nil:
+		out = evaluator.MulRelinNew(upper.ct, giants[j])
+	case upper.constant != 0:
+		out = evaluator.MulNew(giants[j], upper.constant)
+	default:
+		return lower
+	}
+	if lower.ct != nil {
+		evaluator.Add(out, lower.ct, out)
+	} else {
+		evaluator.Add(out, lower.constant, out)
+	}
+
+	return polyChunk{ct: out}
+}
+
+// babySteps returns k, the power of two about sqrt(d+1) the polynomial is
+// split in chunks of
+func babySteps(d uint64) int {
+	k := 1 << ((bits.Len64(d) + 1) / 2)
+	if k < 2 {
+		k = 2
+	}
+
+	return k
+}
+
+// powerDepth returns the depth of ct^i when each power is the product of two
+// lower ones
+func powerDepth(i uint64) int {
+	if i < 2 {
+		return 0
+	}
+
+	return bits.Len64(i - 1)
+}
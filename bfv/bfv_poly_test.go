@@ -0,0 +1,73 @@
+package bfv
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestEvaluatePoly(t *testing.T) {
+	modulus := uint64(65537)
+	bfvParams := GenerateBfvParams(modulus, 1<<14)
+	kgen := rlwe.NewKeyGenerator(bfvParams.Parameters)
+	sk, pk := kgen.GenKeyPairNew()
+	evks := rlwe.NewEvaluationKeySet()
+	evks.RelinearizationKey = kgen.GenRelinearizationKeyNew(sk)
+	evaluator := bfv2.NewEvaluator(bfvParams, evks)
+	encoder := bfv2.NewEncoder(bfvParams)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+
+	values := RandomInputV(8, modulus)
+	pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+	encoder.Encode(values, pt)
+	ct := bfv2.NewEncryptor(bfvParams, pk).EncryptNew(pt)
+
+	polys := [][]uint64{
+		{7},
+		{0, 0, 0},
+		{3, 2},
+		{1, 0, 5, 0, 0, 0, 0, 0, 0, 0, 0, 9},
+		RandomInputV(14, modulus),
+		{0, modulus + 1, 0, 0, 0, 0, 0, 0, 4},
+	}
+	for _, coeffs := range polys {
+		res, err := EvaluatePoly(ct, coeffs, evaluator, bfvParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := make([]uint64, len(values))
+		for i, x := range values {
+			xPow := uint64(1)
+			for _, c := range coeffs {
+				expected[i] = (expected[i] + mulMod(c%modulus, xPow, modulus)) % modulus
+				xPow = mulMod(xPow, x, modulus)
+			}
+		}
+		decrypted := encoder.DecodeUintNew(decryptor.DecryptNew(res))[:len(values)]
+		if !util.EqualSlices(decrypted, expected) {
+			t.Errorf("poly %v: got %v, expected %v", coeffs, decrypted, expected)
+		}
+	}
+
+	if _, err := EvaluatePoly(ct, nil, evaluator, bfvParams); err == nil {
+		t.Errorf("expected an error without coefficients")
+	}
+}
+
+func TestPolyDepth(t *testing.T) {
+	expected := map[uint64]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 2, 14: 4, 16: 4, 17: 5}
+	for d, depth := range expected {
+		if got := PolyDepth(d); got != depth {
+			t.Errorf("depth of degree %d: got %d, expected %d", d, got, depth)
+		}
+	}
+	for d := uint64(2); d < 1024; d++ {
+		if got := PolyDepth(d); got > bits.Len64(d-1)+1 {
+			t.Errorf("depth of degree %d: got %d, more than log2(d)+1", d, got)
+		}
+	}
+}
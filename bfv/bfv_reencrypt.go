@@ -0,0 +1,49 @@
+package bfv
+
+import (
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/dbfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/ring/distribution"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// ReencryptionNoise is the standard deviation of the noise flooding a
+// re-encryption token, it hides the secret key the token was made with
+const ReencryptionNoise = 1 << 30
+
+// GenReencryptionToken is run by the owner of sk, it returns the token that
+// lets anyone move ct from sk to the recipient public key. The token is a
+// single party public key switching (PCKS) share: it only works for ct and
+// reveals nothing about sk, so the node can re-encrypt transciphered results
+// for an end user without ever holding the secret key.
+//
+// There is no reusable key switching key to a public key: the key owner has
+// to be online to generate a token for every ciphertext re-encrypted. A token
+// lets the recipient read ct, only generate them for results meant for it.
+func GenReencryptionToken(params bfv.Parameters, sk *rlwe.SecretKey, recipient *rlwe.PublicKey,
+	ct *rlwe.Ciphertext) *drlwe.PCKSShare {
+
+	pcks := NewPCKSProtocol(params)
+	token := pcks.AllocateShare(ct.Level())
+	pcks.GenShare(sk, recipient, ct, token)
+
+	return token
+}
+
+// Reencrypt moves ct to the recipient public key the token was generated for,
+// the token can be the one of GenReencryptionToken or the aggregated shares
+// of a multiparty key (see multiparty.Session.Reencrypt)
+func Reencrypt(params bfv.Parameters, ct *rlwe.Ciphertext, token *drlwe.PCKSShare) *rlwe.Ciphertext {
+	out := bfv.NewCiphertext(params, 1, ct.Level())
+	NewPCKSProtocol(params).KeySwitch(ct, token, out)
+
+	return out
+}
+
+// NewPCKSProtocol returns the public key switching protocol re-encryption
+// tokens are generated and applied with
+func NewPCKSProtocol(params bfv.Parameters) *drlwe.PCKSProtocol {
+	return dbfv.NewPCKSProtocol(params, &distribution.DiscreteGaussian{Sigma: ReencryptionNoise,
+		Bound: 6 * ReencryptionNoise})
+}
@@ -0,0 +1,32 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestReencrypt(t *testing.T) {
+	bfvParams := GenerateBfvParams(65537, 1<<14)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	recipientSk, recipientPk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+
+	values := RandomInputV(16, bfvParams.T())
+	pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+	encoder.Encode(values, pt)
+	ct := bfv2.NewEncryptor(bfvParams, pk).EncryptNew(pt)
+
+	reencrypted := Reencrypt(bfvParams, ct, GenReencryptionToken(bfvParams, sk, recipientPk, ct))
+
+	decrypted := encoder.DecodeUintNew(bfv2.NewDecryptor(bfvParams, recipientSk).DecryptNew(reencrypted))
+	if !util.EqualSlices(decrypted[:len(values)], values) {
+		t.Errorf("the recipient decrypted %v, expected %v", decrypted[:len(values)], values)
+	}
+	decrypted = encoder.DecodeUintNew(bfv2.NewDecryptor(bfvParams, sk).DecryptNew(reencrypted))
+	if util.EqualSlices(decrypted[:len(values)], values) {
+		t.Errorf("the evaluation key shouldn't decrypt the re-encrypted ciphertext")
+	}
+}
@@ -0,0 +1,114 @@
+package bfv
+
+import (
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Scratch holds the ciphertexts, plaintexts and slot buffers reused by the
+// in-place transcipher path so a pasta block doesn't allocate a new
+// ciphertext per step. Create one per session and reuse it across
+// Transcipher calls, it isn't safe for concurrent use.
+type Scratch struct {
+	params bfv.Parameters
+
+	free   []*rlwe.Ciphertext // degree 1 ciphertexts ready to be reused
+	tensor *rlwe.Ciphertext   // degree 2, holds ct x ct products before relinearization
+	pt     *rlwe.Plaintext
+	values []uint64
+
+	accQP, tmpQP *rlwe.OperandQP
+
+	feistelMask      *rlwe.Plaintext
+	feistelInstances int
+}
+
+// NewScratch creates an empty pool for bfvParams, buffers are allocated on
+// first use
+func NewScratch(bfvParams bfv.Parameters) *Scratch {
+	return &Scratch{params: bfvParams}
+}
+
+// ciphertext takes a degree 1 ciphertext from the pool
+func (s *Scratch) ciphertext() *rlwe.Ciphertext {
+	if n := len(s.free); n > 0 {
+		ct := s.free[n-1]
+		s.free = s.free[:n-1]
+
+		return ct
+	}
+
+	return bfv.NewCiphertext(s.params, 1, s.params.MaxLevel())
+}
+
+// release gives ciphertexts taken with ciphertext back to the pool
+func (s *Scratch) release(cts ...*rlwe.Ciphertext) {
+	s.free = append(s.free, cts...)
+}
+
+func (s *Scratch) tensorCiphertext() *rlwe.Ciphertext {
+	if s.tensor == nil {
+		s.tensor = bfv.NewCiphertext(s.params, 2, s.params.MaxLevel())
+	}
+
+	return s.tensor
+}
+
+// slotValues returns a zeroed buffer with one element per slot
+func (s *Scratch) slotValues() []uint64 {
+	if s.values == nil {
+		s.values = make([]uint64, s.params.N())
+	}
+	for i := range s.values {
+		s.values[i] = 0
+	}
+
+	return s.values
+}
+
+// encode encodes values into the shared plaintext, which is only valid until
+// the next call
+func (s *Scratch) encode(values []uint64, encoder bfv.Encoder) *rlwe.Plaintext {
+	if s.pt == nil {
+		s.pt = bfv.NewPlaintext(s.params, s.params.MaxLevel())
+	}
+	encoder.Encode(values, s.pt)
+
+	return s.pt
+}
+
+func (s *Scratch) operandsQP() (acc, tmp *rlwe.OperandQP) {
+	if s.accQP == nil {
+		levelQ, levelP := s.params.MaxLevel(), s.params.MaxLevelP()
+		s.accQP = rlwe.NewOperandQP(s.params.Parameters, 1, levelQ, levelP)
+		s.tmpQP = rlwe.NewOperandQP(s.params.Parameters, 1, levelQ, levelP)
+		s.accQP.IsNTT, s.tmpQP.IsNTT = true, true
+	}
+
+	return s.accQP, s.tmpQP
+}
+
+// sboxFeistelMask returns the mask dropping the first element of each pasta
+// branch of the first instances, it only depends on the parameters and the
+// number of instances so it's encoded once
+func (s *Scratch) sboxFeistelMask(instances int, encoder bfv.Encoder) *rlwe.Plaintext {
+	if s.feistelMask == nil || s.feistelInstances != instances {
+		halfslots := uint64(s.params.N() / 2)
+		maskVec := make([]uint64, s.params.N())
+		for m := 0; m < instances; m++ {
+			offset := uint64(m) * BatchWindow
+			for i := uint64(1); i < pasta.T; i++ {
+				maskVec[offset+i] = 1
+				maskVec[halfslots+offset+i] = 1
+			}
+		}
+		if s.feistelMask == nil {
+			s.feistelMask = bfv.NewPlaintext(s.params, s.params.MaxLevel())
+		}
+		encoder.Encode(maskVec, s.feistelMask)
+		s.feistelInstances = instances
+	}
+
+	return s.feistelMask
+}
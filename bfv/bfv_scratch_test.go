@@ -0,0 +1,172 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestInPlace(t *testing.T) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(modulus, degree)
+	scratch := NewScratch(bfv.Params)
+
+	values := RandomInputV(int(degree), modulus)
+	pt := bfv2.NewPlaintext(bfv.Params, bfv.Params.MaxLevel())
+	encoder.Encode(values, pt)
+	ct := encryptor.EncryptNew(pt)
+
+	for _, tc := range []struct {
+		name     string
+		expected *rlwe.Ciphertext
+		inPlace  func(*rlwe.Ciphertext)
+	}{
+		{"Mix", Mix(ct, evaluator, encoder), func(ct *rlwe.Ciphertext) {
+			mixInPlace(ct, scratch, evaluator)
+		}},
+		{"SboxCube", SboxCube(ct, evaluator), func(ct *rlwe.Ciphertext) {
+			sboxCubeInPlace(ct, scratch, evaluator)
+		}},
+		{"SboxFeistel", SboxFeistel(ct, degree/2, evaluator, encoder, bfv.Params), func(ct *rlwe.Ciphertext) {
+			sboxFeistelInPlace(ct, 1, scratch, encoder, evaluator)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := ct.CopyNew()
+			tc.inPlace(res)
+
+			expected := DecryptPacked(Unpacked(tc.expected, degree), decryptor, encoder)
+			if !util.EqualSlices(DecryptPacked(Unpacked(res, degree), decryptor, encoder), expected) {
+				t.Errorf("in place %s differs from %s", tc.name, tc.name)
+			}
+		})
+	}
+}
+
+func TestTranscipherScratch(t *testing.T) {
+	tc := testCases()[1]
+	bfvParams := GenerateBfvParams(tc.modulus, tc.bfvPolyDegree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	evks := GenTranscipherKeys(bfvParams, sk, 3, TranscipherOptions{})
+	encoder := bfv2.NewEncoder(bfvParams)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+	pastaSKCt := EncryptPastaSecretKey(tc.secretKey, encoder, bfv2.NewEncryptor(bfvParams, pk), bfvParams)
+	pastaCipher := pasta.NewPasta(tc.secretKey, tc.modulus, PastaParams)
+
+	evaluator, _, _, err := NewBFVPastaServer(tc.bfvPolyDegree, tc.modulus, &evks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same scratch is reused across messages
+	scratch := NewScratch(bfvParams)
+	for _, message := range [][]uint64{{1, 2, 3}, {4, 5}} {
+		res, err := TranscipherScratch(pastaCipher.Encrypt(message), pastaSKCt, PastaParams, pasta.DefaultSecLevel,
+			encoder, evaluator, scratch, TranscipherOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decrypted := DecryptPacked(res, decryptor, encoder)
+		if !util.EqualSlices(decrypted, message) {
+			t.Errorf("decrypted %v, expected %v", decrypted, message)
+		}
+	}
+}
+
+// BenchmarkPastaRound compares one pasta round evaluated with the allocating
+// functions against the in-place path sharing a scratch pool, run it with
+// -benchmem to see the allocation reduction
+func BenchmarkPastaRound(b *testing.B) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	encryptor, _, evaluator, encoder, bfv, _ := newBFV(modulus, degree)
+	halfslots := degree / 2
+
+	pastaUtil, _ := newPastaUtil(modulus)
+	pastaUtil.InitShake(pasta.Nonce, 0)
+	mat1 := pastaUtil.RandomMatrix()
+	mat2 := pastaUtil.RandomMatrix()
+	rc := pastaUtil.RCVec(halfslots)
+
+	pt := bfv2.NewPlaintext(bfv.Params, bfv.Params.MaxLevel())
+	encoder.Encode(RandomInputV(int(degree), modulus), pt)
+	ct := encryptor.EncryptNew(pt)
+
+	b.Run("Allocating", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			state := Matmul(ct, mat1, mat2, degree, halfslots, evaluator, encoder, bfv.Params, true)
+			state = AddRc(state, rc, encoder, evaluator, bfv.Params)
+			state = Mix(state, evaluator, encoder)
+			SboxFeistel(state, halfslots, evaluator, encoder, bfv.Params)
+		}
+	})
+
+	b.Run("Scratch", func(b *testing.B) {
+		scratch := NewScratch(bfv.Params)
+		state := ct.CopyNew()
+		round := func() {
+			state.Copy(ct)
+			matmulInPlace(state, []pastaMatrices{{mat1, mat2}}, MatmulBsgs, BsgsOptions{}, scratch, encoder,
+				evaluator)
+			addRcInPlace(state, rc, scratch, encoder, evaluator)
+			mixInPlace(state, scratch, evaluator)
+			sboxFeistelInPlace(state, 1, scratch, encoder, evaluator)
+		}
+		round() // fills the pool
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			round()
+		}
+	})
+}
+
+// BenchmarkMatmul compares the bsgs matmul with chained baby steps against
+// hoisted ones, galois-MB is the size of the transcipher galois keys each one
+// needs, what the client ships to the server
+func BenchmarkMatmul(b *testing.B) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	bfvParams := GenerateBfvParams(modulus, degree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+
+	pastaUtil, _ := newPastaUtil(modulus)
+	pastaUtil.InitShake(pasta.Nonce, 0)
+	mats := []pastaMatrices{{pastaUtil.RandomMatrix(), pastaUtil.RandomMatrix()}}
+
+	pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+	encoder.Encode(RandomInputV(int(degree), modulus), pt)
+	ct := bfv2.NewEncryptor(bfvParams, pk).EncryptNew(pt)
+
+	for _, tc := range []struct {
+		name string
+		bsgs BsgsOptions
+	}{
+		{"Hoisted", BsgsOptions{}},
+		{"Chained", BsgsOptions{Chained: true}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			evks := GenTranscipherKeys(bfvParams, sk, pasta.DefaultSecLevel, TranscipherOptions{Bsgs: tc.bsgs})
+			evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+			scratch := NewScratch(bfvParams)
+			state := ct.CopyNew()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				state.Copy(ct)
+				matmulInPlace(state, mats, MatmulBsgs, tc.bsgs, scratch, encoder, evaluator)
+			}
+
+			size := 0
+			for _, gk := range evks.GaloisKeys {
+				size += gk.BinarySize()
+			}
+			b.ReportMetric(float64(size)/(1<<20), "galois-MB")
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package bfv
+
+import (
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// SumSlots returns a ciphertext whose slot i of each row holds the sum of the
+// n slots of ct starting at i (wrapping around the row), so slot 0 holds the
+// sum of the first n slots. It takes about 2*log2(n) rotations, the keys are
+// given by SumSlotsGaloisElements.
+func SumSlots(ct *rlwe.Ciphertext, n uint64, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+	out := ct.CopyNew()
+	doublingSum(out, 1, n, evaluator, NewScratch(bfvParams))
+
+	return out
+}
+
+// InnerSum returns a ciphertext holding the sum of every slot of ct, on both
+// rows, in every slot. The keys are given by InnerSumGaloisElements.
+func InnerSum(ct *rlwe.Ciphertext, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+	out := ct.CopyNew()
+	scratch := NewScratch(bfvParams)
+	doublingSum(out, 1, uint64(bfvParams.N()/2), evaluator, scratch)
+
+	rows := scratch.ciphertext()
+	evaluator.RotateRows(out, rows)
+	evaluator.Add(out, rows, out)
+	scratch.release(rows)
+
+	return out
+}
+
+// InnerProduct returns a ciphertext whose slot 0 of each row holds the inner
+// product of the first n slots of ct1 and ct2, the other slots hold the inner
+// products of the following windows as in SumSlots. It needs the
+// relinearization key and the keys of SumSlotsGaloisElements.
+func InnerProduct(ct1, ct2 *rlwe.Ciphertext, n uint64, evaluator bfv.Evaluator,
+	bfvParams bfv.Parameters) *rlwe.Ciphertext {
+
+	out := evaluator.MulRelinNew(ct1, ct2)
+	doublingSum(out, 1, n, evaluator, NewScratch(bfvParams))
+
+	return out
+}
+
+// Replicate returns a ciphertext with the first n slots of each row of ct
+// repeated all along the row, as many whole times as they fit. The rest of
+// each row of ct must be zero. The keys are given by ReplicateGaloisElements.
+func Replicate(ct *rlwe.Ciphertext, n uint64, evaluator bfv.Evaluator, bfvParams bfv.Parameters) *rlwe.Ciphertext {
+	out := ct.CopyNew()
+	if n == 0 {
+		return out
+	}
+	doublingSum(out, -int(n), uint64(bfvParams.N()/2)/n, evaluator, NewScratch(bfvParams))
+
+	return out
+}
+
+// SumSlotsGaloisElements returns the sorted set of galois elements SumSlots
+// and InnerProduct use on windows of n slots
+func SumSlotsGaloisElements(params rlwe.Parameters, n uint64) []uint64 {
+	return galoisElements(params, doublingRotations(1, n))
+}
+
+// InnerSumGaloisElements returns the sorted set of galois elements InnerSum
+// uses
+func InnerSumGaloisElements(params rlwe.Parameters) []uint64 {
+	rots := append(doublingRotations(1, uint64(params.N()/2)), 0) // 0 is the row rotation
+
+	return galoisElements(params, rots)
+}
+
+// ReplicateGaloisElements returns the sorted set of galois elements Replicate
+// uses on the first n slots
+func ReplicateGaloisElements(params rlwe.Parameters, n uint64) []uint64 {
+	if n == 0 {
+		return nil
+	}
+
+	return galoisElements(params, doublingRotations(-int(n), uint64(params.N()/2)/n))
+}
+
+// GenSlotKeys generates from sk the relinearization key and the galois keys
+// of galEls, usually the union of the *GaloisElements above
+func GenSlotKeys(bfvParams bfv.Parameters, sk *rlwe.SecretKey, galEls []uint64) rlwe.EvaluationKeySet {
+	rk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenRelinearizationKeyNew(sk)
+
+	return *GenEvks(bfvParams.Parameters, galEls, sk, rk)
+}
+
+// doublingRotations returns the column rotations doublingSum performs
+func doublingRotations(step int, r uint64) []int {
+	var rots []int
+
+	shift := 0
+	for j := uint(0); uint64(1)<<j <= r; j++ {
+		if r&(1<<j) != 0 {
+			if shift != 0 {
+				rots = append(rots, shift)
+			}
+			shift += (1 << j) * step
+		}
+		if uint64(1)<<(j+1) <= r {
+			rots = append(rots, (1<<j)*step)
+		}
+	}
+
+	return rots
+}
+
+// doublingSum replaces ct by the sum of ct rotated by k*step for k < r, in
+// log2(r) doublings: pow holds the sum of 2^j rotations and ct the sum for
+// the bits of r seen so far. A negative step replicates a window, a step of 1
+// sums one.
+func doublingSum(ct *rlwe.Ciphertext, step int, r uint64, evaluator bfv.Evaluator, scratch *Scratch) {
+	if r <= 1 {
+		return
+	}
+
+	pow := scratch.ciphertext()
+	tmp := scratch.ciphertext()
+	defer scratch.release(pow, tmp)
+	pow.Copy(ct)
+
+	shift := 0
+	for j := uint(0); uint64(1)<<j <= r; j++ {
+		if r&(1<<j) != 0 {
+			if shift == 0 {
+				ct.Copy(pow)
+			} else {
+				evaluator.RotateColumns(pow, shift, tmp)
+				evaluator.Add(ct, tmp, ct)
+			}
+			shift += (1 << j) * step
+		}
+		if uint64(1)<<(j+1) <= r {
+			evaluator.RotateColumns(pow, (1<<j)*step, tmp)
+			evaluator.Add(pow, tmp, pow)
+		}
+	}
+}
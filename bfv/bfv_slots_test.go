@@ -0,0 +1,91 @@
+package bfv
+
+import (
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+	bfv2 "github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestSlots(t *testing.T) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	bfvParams := GenerateBfvParams(modulus, degree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+	halfslots := degree / 2
+
+	n := uint64(13)
+	var galEls []uint64
+	galEls = append(galEls, SumSlotsGaloisElements(bfvParams.Parameters, n)...)
+	galEls = append(galEls, InnerSumGaloisElements(bfvParams.Parameters)...)
+	galEls = append(galEls, ReplicateGaloisElements(bfvParams.Parameters, n)...)
+	evks := GenSlotKeys(bfvParams, sk, galEls)
+	evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+
+	encrypt := func(values []uint64) *rlwe.Ciphertext {
+		pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+		encoder.Encode(values, pt)
+		return encryptor.EncryptNew(pt)
+	}
+	decrypt := func(ct *rlwe.Ciphertext) []uint64 {
+		return encoder.DecodeUintNew(decryptor.DecryptNew(ct))
+	}
+
+	// n values on the first row, garbage on the second one
+	v1 := make([]uint64, degree)
+	v2 := make([]uint64, degree)
+	copy(v1, RandomInputV(int(n), modulus))
+	copy(v2, RandomInputV(int(n), modulus))
+	v1[halfslots] = 7
+	ct1, ct2 := encrypt(v1), encrypt(v2)
+
+	var sum, product, total uint64
+	for k := uint64(0); k < n; k++ {
+		sum = (sum + v1[k]) % modulus
+		product = (product + v1[k]*v2[k]) % modulus
+	}
+	total = (sum + 7) % modulus
+
+	if got := decrypt(SumSlots(ct1, n, evaluator, bfvParams)); got[0] != sum || got[halfslots] != 7 {
+		t.Errorf("SumSlots: got %d, expected %d", got[0], sum)
+	}
+	if got := decrypt(InnerProduct(ct1, ct2, n, evaluator, bfvParams)); got[0] != product {
+		t.Errorf("InnerProduct: got %d, expected %d", got[0], product)
+	}
+	got := decrypt(InnerSum(ct1, evaluator, bfvParams))
+	for i, v := range got {
+		if v != total {
+			t.Fatalf("InnerSum: slot %d holds %d, expected %d", i, v, total)
+		}
+	}
+
+	got = decrypt(Replicate(ct1, n, evaluator, bfvParams))
+	for i := uint64(0); i < halfslots; i++ {
+		expected := v1[i%n]
+		if i >= halfslots/n*n {
+			expected = 0
+		}
+		if got[i] != expected {
+			t.Fatalf("Replicate: slot %d holds %d, expected %d", i, got[i], expected)
+		}
+	}
+}
+
+func TestSlotsGaloisElements(t *testing.T) {
+	params := GenerateBfvParams(65537, 1<<14).Parameters
+
+	// 13 = 0b1101, doublings by 1, 2 and 4 plus a shift by 5
+	if n := len(SumSlotsGaloisElements(params, 13)); n != 4 {
+		t.Errorf("expected 4 galois elements, got %d", n)
+	}
+	// 13 doublings and the row rotation
+	if n := len(InnerSumGaloisElements(params)); n != 14 {
+		t.Errorf("expected 14 galois elements, got %d", n)
+	}
+	if !util.EqualSlices(ReplicateGaloisElements(params, 1<<12), galoisElements(params, []int{-(1 << 12)})) {
+		t.Errorf("replicating half a row takes a single rotation")
+	}
+}
@@ -28,11 +28,12 @@ func TestBfv(t *testing.T) {
 		t.Run(fmt.Sprintf("Test_EncryptPastaSK %d", i), func(t *testing.T) {
 			pastaSK := tc.secretKey
 			modulus := tc.modulus
-			encryptor, decryptor, _, encoder, bfv := NewBFV(modulus, tc.bfvPolyDegree)
+			encryptor, decryptor, _, encoder, bfv, _ := newBFV(modulus, tc.bfvPolyDegree)
 
 			ciphSK := EncryptPastaSecretKey(pastaSK, encoder, encryptor, bfv.Params)
 
-			d := DecryptPacked(ciphSK, uint64(len(pastaSK)), decryptor, encoder)
+			// the key spans both rows
+			d := DecryptPacked(Unpacked(ciphSK, uint64(bfv.Params.N())), decryptor, encoder)
 			if !util.EqualSlices(pastaSK[:pasta.T], d[:pasta.T]) {
 				t.Errorf("decrypted different pasta SK 1")
 			}
@@ -49,13 +50,45 @@ func TestBfv(t *testing.T) {
 			bfvPolyDegree := tc.bfvPolyDegree
 			pastaSecLevel := tc.pastaSecLevel
 			testTranscipher(t, pastaSecretKey, plaintext, ciphertextExpected, modulus, bfvPolyDegree, pastaSecLevel,
-				20, 10, true)
+				TranscipherOptions{}, TranscipherOptions{})
+		})
+		t.Run(fmt.Sprintf("Test_TranscipherDiagonal %d", i), func(t *testing.T) {
+			testTranscipher(t, tc.secretKey, tc.plaintext, tc.ciphertextExpected, tc.modulus, tc.bfvPolyDegree,
+				tc.pastaSecLevel, TranscipherOptions{Matmul: MatmulDiagonal}, TranscipherOptions{Matmul: MatmulDiagonal})
+		})
+		t.Run(fmt.Sprintf("Test_TranscipherChained %d", i), func(t *testing.T) {
+			chained := TranscipherOptions{Bsgs: BsgsOptions{Chained: true}}
+			testTranscipher(t, tc.secretKey, tc.plaintext, tc.ciphertextExpected, tc.modulus, tc.bfvPolyDegree,
+				tc.pastaSecLevel, chained, chained)
+		})
+		t.Run(fmt.Sprintf("Test_TranscipherSplit %d", i), func(t *testing.T) {
+			split := TranscipherOptions{Bsgs: BsgsOptions{N1: 8, N2: 16}}
+			testTranscipher(t, tc.secretKey, tc.plaintext, tc.ciphertextExpected, tc.modulus, tc.bfvPolyDegree,
+				tc.pastaSecLevel, split, split)
+		})
+		t.Run(fmt.Sprintf("Test_TranscipherAuto %d", i), func(t *testing.T) {
+			// only the diagonal keys are there, auto has to fall back to it
+			testTranscipher(t, tc.secretKey, tc.plaintext, tc.ciphertextExpected, tc.modulus, tc.bfvPolyDegree,
+				tc.pastaSecLevel, TranscipherOptions{Matmul: MatmulDiagonal}, TranscipherOptions{Matmul: MatmulAuto})
+		})
+		t.Run(fmt.Sprintf("Test_TranscipherLayout %d", i), func(t *testing.T) {
+			// a partial block, spreading has to drop its tail. The baby steps
+			// are chained, hoisted 2^16 keys with the layout ones take ~4.6 GB
+			layout := TranscipherOptions{Bsgs: BsgsOptions{Chained: true},
+				Layout: Layout{Offset: 1, Stride: 2, Replication: 3}}
+			testTranscipher(t, tc.secretKey, tc.plaintext[:100], tc.ciphertextExpected[:100], tc.modulus,
+				tc.bfvPolyDegree, tc.pastaSecLevel, layout, layout)
 		})
 	}
 }
 
-func testTranscipher(t *testing.T, pastaSecretKey, plaintext, ciphertextExpected []uint64, plainMod, bfvPolyDegree, secLevel,
-	bsgN1, bsgN2 uint64, useBsGs bool) {
+func testTranscipher(t *testing.T, pastaSecretKey, plaintext, ciphertextExpected []uint64, plainMod, bfvPolyDegree, secLevel uint64,
+	keys, opts TranscipherOptions) {
+	// the 3 pasta rounds leave about 350 bits of noise and 2^14 parameters
+	// only take 278 (see noiseprof), their vectors only check EncryptPastaSecretKey
+	if bfvPolyDegree < 1<<15 {
+		t.Skip("2^14 parameters don't have the noise budget to transcipher")
+	}
 	messageLength := uint64(len(plaintext))
 
 	if messageLength != uint64(len(ciphertextExpected)) {
@@ -64,21 +97,29 @@ func testTranscipher(t *testing.T, pastaSecretKey, plaintext, ciphertextExpected
 
 	bfvParams := GenerateBfvParams(plainMod, bfvPolyDegree)
 	keygen := rlwe.NewKeyGenerator(bfvParams.Parameters)
-	sk, _ := keygen.GenKeyPairNew()
-	rk := keygen.GenRelinearizationKeyNew(sk)
+	sk, pk := keygen.GenKeyPairNew()
 
-	// create bfv cipher
-	encryptor, decryptor, evaluator, encoder, _, _ := NewBFVPasta(bfvPolyDegree, secLevel, messageLength, bsgN1, bsgN2, plainMod, sk, rk)
+	// create bfv cipher with the keys of the given matmul strategy
+	evks := GenTranscipherKeys(bfvParams, sk, messageLength, keys)
+	evaluator, encoder, _, err := NewBFVPastaServer(bfvPolyDegree, plainMod, &evks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
 
 	// homomorphically encrypt secret key
 	pastaSKCiphertext := EncryptPastaSecretKey(pastaSecretKey, encoder, encryptor, bfvParams)
 
 	// move from PASTA ciphertext to BFV ciphertext
-	bfvCiphertext := Transcipher(ciphertextExpected, pastaSKCiphertext, PastaParams, secLevel,
-		encoder, evaluator, bfvParams)
+	bfvCiphertext, err := TranscipherScratch(ciphertextExpected, pastaSKCiphertext, PastaParams, secLevel,
+		encoder, evaluator, NewScratch(bfvParams), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// final decrypt
-	decrypted := DecryptPacked(&bfvCiphertext, messageLength, decryptor, encoder)
+	decrypted := DecryptPacked(bfvCiphertext, decryptor, encoder)
 	if !util.EqualSlices(decrypted, plaintext) {
 		t.Errorf("decrypted a different vector")
 		fmt.Printf("messageLength = %d\n", messageLength)
@@ -87,6 +128,103 @@ func testTranscipher(t *testing.T, pastaSecretKey, plaintext, ciphertextExpected
 	}
 }
 
+// TestPackPastaBlocks feeds already transciphered blocks to the packing step,
+// running pasta for a message longer than one ciphertext would take too long
+func TestPackPastaBlocks(t *testing.T) {
+	modulus, degree := uint64(65537), uint64(1<<14)
+	bfvParams := GenerateBfvParams(modulus, degree)
+	sk, pk := rlwe.NewKeyGenerator(bfvParams.Parameters).GenKeyPairNew()
+	encoder := bfv2.NewEncoder(bfvParams)
+	encryptor := bfv2.NewEncryptor(bfvParams, pk)
+	decryptor := bfv2.NewDecryptor(bfvParams, sk)
+
+	secLevel := uint64(pasta.DefaultSecLevel)
+	halfslots := degree / 2
+	capacity := TranscipherCapacity(bfvParams, secLevel, PackFirstRow)
+	if capacity != halfslots || TranscipherCapacity(bfvParams, secLevel, PackBothRows) != degree {
+		t.Fatalf("expected a capacity of %d slots per row, got %d", halfslots, capacity)
+	}
+
+	// one full row and 5 elements more
+	messageLength := capacity + 5
+	message := RandomInputV(int(messageLength), modulus)
+	evks := GenTranscipherKeys(bfvParams, sk, messageLength, TranscipherOptions{Matmul: MatmulDiagonal})
+	evaluator := bfv2.NewEvaluator(bfvParams, &evks)
+
+	blocks := func() []rlwe.Ciphertext {
+		var blocks []rlwe.Ciphertext
+		for start := uint64(0); start < messageLength; start += secLevel {
+			end := start + secLevel
+			if end > messageLength {
+				end = messageLength
+			}
+			// the tail of the last block and the second row are garbage the
+			// packing has to drop
+			block := make([]uint64, halfslots+secLevel)
+			copy(block, message[start:end])
+			for i := end - start; i < secLevel; i++ {
+				block[i] = 1
+			}
+			for i := uint64(0); i < secLevel; i++ {
+				block[halfslots+i] = 2
+			}
+			pt := bfv2.NewPlaintext(bfvParams, bfvParams.MaxLevel())
+			encoder.Encode(block, pt)
+			blocks = append(blocks, *encryptor.EncryptNew(pt))
+		}
+
+		return blocks
+	}
+
+	for _, tc := range []struct {
+		packing  Packing
+		expected []PackedCiphertext
+	}{
+		{PackFirstRow, []PackedCiphertext{{Start: 0, End: capacity, RowLength: capacity},
+			{Start: capacity, End: messageLength, RowLength: 5}}},
+		{PackBothRows, []PackedCiphertext{{Start: 0, End: messageLength, RowLength: capacity}}},
+	} {
+		opts := TranscipherOptions{Packing: tc.packing}
+		packed := packPastaBlocks(blocks(), secLevel, messageLength, opts, evaluator, encoder, NewScratch(bfvParams))
+		if len(packed) != len(tc.expected) {
+			t.Fatalf("expected %d ciphertexts, got %d", len(tc.expected), len(packed))
+		}
+		for i, p := range packed {
+			e := tc.expected[i]
+			if p.Start != e.Start || p.End != e.End || p.RowLength != e.RowLength {
+				t.Errorf("packing %d: got [%d, %d) with %d in the first row, expected [%d, %d) with %d",
+					tc.packing, p.Start, p.End, p.RowLength, e.Start, e.End, e.RowLength)
+			}
+		}
+		if !util.EqualSlices(DecryptPacked(packed, decryptor, encoder), message) {
+			t.Errorf("packing %d: decrypted a different message", tc.packing)
+		}
+		data, err := MarshalPackedCiphertexts(packed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unmarshaled, err := UnmarshalPackedCiphertexts(data, bfvParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !util.EqualSlices(DecryptPacked(unmarshaled, decryptor, encoder), message) {
+			t.Errorf("packing %d: the message changed in a marshal round trip", tc.packing)
+		}
+		if _, err := UnmarshalPackedCiphertexts(data[:len(data)-1], bfvParams); err == nil {
+			t.Errorf("packing %d: expected an error for truncated packed ciphertexts", tc.packing)
+		}
+
+		last := packed[len(packed)-1]
+		slots := DecryptPacked(Unpacked(last.Ciphertext, degree), decryptor, encoder)
+		if tc.packing == PackBothRows && (slots[halfslots+5] != 0 || slots[halfslots+secLevel] != 0) {
+			t.Errorf("the second row wasn't masked")
+		}
+		if tc.packing == PackFirstRow && slots[5] != 0 {
+			t.Errorf("the last block wasn't masked")
+		}
+	}
+}
+
 func testCases() []BFVTestCase {
 	tcs := []BFVTestCase{
 		{
@@ -471,7 +609,8 @@ func testCases() []BFVTestCase {
 	return tcs
 }
 
-func NewBFV(modulus, polyDegree uint64) (rlwe.Encryptor, rlwe.Decryptor, bfv2.Evaluator, bfv2.Encoder, Params) {
+func newBFV(modulus, polyDegree uint64) (rlwe.Encryptor, rlwe.Decryptor, bfv2.Evaluator, bfv2.Encoder, Params,
+	rlwe.EvaluationKeySet) {
 	bfvParams := GenerateBfvParams(modulus, polyDegree)
 	keygen := bfv2.NewKeyGenerator(bfvParams)
 	s, _ := keygen.GenKeyPairNew()
@@ -479,7 +618,6 @@ func NewBFV(modulus, polyDegree uint64) (rlwe.Encryptor, rlwe.Decryptor, bfv2.Ev
 	bfvEvaluator := bfv2.NewEvaluator(bfvParams, &evk)
 	bfvEncoder := bfv2.NewEncoder(bfvParams)
 
-	encryptor, decryptor, evaluator, _, cipher := NewBFV(bfvParams, s, bfvEvaluator, bfvEncoder, evk) // todo(fedejinich) this is ugly
-
-	return encryptor, decryptor, evaluator, bfvEncoder, cipher
+	return NewBFV(bfvParams, bfv2.NewEncryptor(bfvParams, s), bfv2.NewDecryptor(bfvParams, s), bfvEvaluator,
+		bfvEncoder, evk)
 }
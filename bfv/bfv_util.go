@@ -3,6 +3,7 @@ package bfv
 import (
 	"fmt"
 	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
 	"github.com/tuneinsight/lattigo/v4/bfv"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"math"
@@ -16,16 +17,16 @@ const BsgsN1 = 16
 // BsgsN2 used for babystep-gigantstep
 const BsgsN2 = 8
 
-func GenerateBfvParams(modulus uint64, degree uint64) bfv.Parameters {
+// NewBfvParams returns the bfv parameters for a supported degree (2^14, 2^15
+// or 2^16) and plaintext modulus. The modulus has to be a prime congruent to 1
+// mod 2*degree, it fails otherwise.
+func NewBfvParams(modulus uint64, degree uint64) (bfv.Parameters, error) {
 	var bfvParams bfv.ParametersLiteral
 	if degree == uint64(math.Pow(2, 14)) {
-		fmt.Println("polynomial modDegree (LogN) = 2^14 (16384)")
 		bfvParams = bfv.PN14QP411pq // post-quantum Params
 	} else if degree == uint64(math.Pow(2, 15)) {
-		fmt.Println("polynomial modDegree (LogN) = 2^15 (32768)")
 		bfvParams = bfv.PN15QP827pq // post-quantum Params
 	} else if degree == uint64(math.Pow(2, 16)) {
-		fmt.Println("polynomial modDegree (LogN) = 2^16 (65536)")
 		bfvParams = bfv.ParametersLiteral{
 			LogN: 16,
 			T:    0xffffffffffc0001,
@@ -60,16 +61,26 @@ func GenerateBfvParams(modulus uint64, degree uint64) bfv.Parameters {
 				0x2000000000500001},
 		}
 	} else {
-		panic(fmt.Sprintf("polynomial modDegree not supported (modDegree)"))
+		return bfv.Parameters{}, fmt.Errorf("polynomial degree %d not supported", degree)
 	}
 
-	fmt.Println(fmt.Sprintf("modulus (T) = %d", modulus))
 	bfvParams.T = modulus
 
 	params, err := bfv.NewParametersFromLiteral(bfvParams)
 	if err != nil {
-		panic(fmt.Sprintf("couldn't initialize bfvParams"))
+		return bfv.Parameters{}, fmt.Errorf("plaintext modulus %d not supported: %w", modulus, err)
+	}
+
+	return params, nil
+}
+
+func GenerateBfvParams(modulus uint64, degree uint64) bfv.Parameters {
+	params, err := NewBfvParams(modulus, degree)
+	if err != nil {
+		panic(err)
 	}
+	fmt.Fprintf(util.Progress, "polynomial modDegree (LogN) = 2^%d (%d)\n", params.LogN(), degree)
+	fmt.Fprintf(util.Progress, "modulus (T) = %d\n", modulus)
 
 	return params
 }
@@ -114,40 +125,6 @@ func bytesToMB(bytes int64) float64 {
 	return float64(bytes) / 1048576.0 // 1048576 = 1024 * 1024
 }
 
-func addGkIndices(gkIndices []int, degree uint64, useBsGs bool) []int {
-	gkIndices = append(gkIndices, 0)
-	gkIndices = append(gkIndices, -1)
-	if pasta.T*2 != degree {
-		gkIndices = append(gkIndices, pasta.T)
-	}
-	if useBsGs {
-		for k := uint64(1); k < BsgsN2; k++ {
-			gkIndices = append(gkIndices, int(-k*BsgsN1))
-		}
-	}
-	return gkIndices
-}
-
-func addBsGsIndices(n1 uint64, n2 uint64, gkIndices *[]int, slots uint64) {
-	mul := n1 * n2
-	addDiagonalIndices(mul, gkIndices, slots)
-
-	if n1 == 1 || n2 == 1 {
-		return
-	}
-
-	for k := uint64(1); k < n2; k++ {
-		*gkIndices = append(*gkIndices, int(k*n1))
-	}
-}
-
-func addDiagonalIndices(messageLength uint64, gkIndices *[]int, slots uint64) {
-	if messageLength*2 != slots {
-		*gkIndices = append(*gkIndices, -int(messageLength))
-	}
-	*gkIndices = append(*gkIndices, 1)
-}
-
 func BasicEvaluationKeys(parameters rlwe.Parameters, keygen rlwe.KeyGenerator, sk *rlwe.SecretKey) rlwe.EvaluationKeySet {
 	galEl := parameters.GaloisElementForColumnRotationBy(-1)
 	galEl2 := parameters.GaloisElementForRowRotation()
@@ -159,21 +136,37 @@ func BasicEvaluationKeys(parameters rlwe.Parameters, keygen rlwe.KeyGenerator, s
 	for k := 0; k < BsgsN2; k++ {
 		galEls = append(galEls, parameters.GaloisElementForColumnRotationBy(-k*BsgsN1))
 	}
+	for j := 2; j < BsgsN1; j++ {
+		galEls = append(galEls, parameters.GaloisElementForColumnRotationBy(-j))
+	}
 
 	return *GenEvks(parameters, galEls, sk, keygen.GenRelinearizationKeyNew(sk))
 }
 
 func NoiseBudget(decryptor rlwe.Decryptor, encoder bfv.Encoder, evaluator bfv.Evaluator,
-	el *rlwe.Ciphertext) (float64) {
+	el *rlwe.Ciphertext) float64 {
+	res, _, _ := NoiseStats(decryptor, encoder, evaluator, el)
+
+	fmt.Fprintf(util.Progress, "STD(noise): %f\n", res)
+
+	return res
+}
+
+// NoiseStats returns the log2 of the standard deviation, minimum and maximum
+// of the noise in el, measured against its own decryption.
+func NoiseStats(decryptor rlwe.Decryptor, encoder bfv.Encoder, evaluator bfv.Evaluator,
+	el *rlwe.Ciphertext) (std, min, max float64) {
 	pt := decryptor.DecryptNew(el)
 	val := encoder.DecodeUintNew(pt)
 	encoder.Encode(val, pt)
 
 	ct := evaluator.SubNew(el, pt)
 
-	res, _, _ := rlwe.Norm(ct, decryptor)
+	return rlwe.Norm(ct, decryptor)
+}
 
-	fmt.Printf("STD(noise): %f\n", res)
-	
-	return res
+// NoiseBound returns the log2 of the largest noise a ciphertext can carry
+// and still decrypt correctly, that is Q/(2T).
+func NoiseBound(params bfv.Parameters) float64 {
+	return params.LogQ() - params.LogT() - 1
 }
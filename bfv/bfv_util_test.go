@@ -23,7 +23,7 @@ func TestUtil(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
 			pastaUtil.InitShake(uint64(123456789), 0)
 
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -50,12 +50,12 @@ func TestUtil(t *testing.T) {
 			ct = Matmul(ct, mat1, mat2, tc.bfvDegree, uint64(tc.Halfslots()),
 				evaluator, encoder, bfv.Params, false)
 
-			state1 := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			state1 := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(state1, toVec(s1)) { // assert for the 1st pasta branch
 				t.Errorf("bfv Matmul is not the same as pasta Matmul")
 			}
 
-			state2 := DecryptPacked(ct, uint64(tc.Halfslots()+pasta.T), decryptor, encoder)[tc.Halfslots():]
+			state2 := DecryptPacked(Unpacked(ct, uint64(tc.Halfslots()+pasta.T)), decryptor, encoder)[tc.Halfslots():]
 			if !util.EqualSlices(state2, toVec(s2)) { // assert for the 2nd pasta branch
 				t.Errorf("bfv Matmul is not the same as pasta Matmul")
 			}
@@ -64,7 +64,7 @@ func TestUtil(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
 			pastaUtil.InitShake(uint64(123456789), 0)
 
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -91,19 +91,19 @@ func TestUtil(t *testing.T) {
 			ct = Matmul(ct, mat1, mat2, tc.bfvDegree, uint64(tc.Halfslots()),
 				evaluator, encoder, bfv.Params, true)
 
-			state1 := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			state1 := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(state1, toVec(s1)) { // assert for the 1st pasta branch
 				t.Errorf("bfv Matmul is not the same as pasta Matmul")
 			}
 
-			state2 := DecryptPacked(ct, uint64(tc.Halfslots()+pasta.T), decryptor, encoder)[tc.Halfslots():]
+			state2 := DecryptPacked(Unpacked(ct, uint64(tc.Halfslots()+pasta.T)), decryptor, encoder)[tc.Halfslots():]
 			if !util.EqualSlices(state2, toVec(s2)) { // assert for the 2nd pasta branch
 				t.Errorf("bfv Matmul is not the same as pasta Matmul")
 			}
 		})
 		t.Run("TestUtil_AddRc", func(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -128,12 +128,12 @@ func TestUtil(t *testing.T) {
 			pastaUtil.AddRcBy(s1, rcVec)
 			pastaUtil.AddRcBy(s2, rcVec[tc.Halfslots():])
 
-			decrypted := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			decrypted := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(decrypted, toVec(s1)) {
 				t.Errorf("bfv AddRc is not the same as pasta AddRc")
 			}
 
-			decrypted2 := DecryptPacked(ct, uint64(tc.Halfslots()+pasta.T), decryptor, encoder)
+			decrypted2 := DecryptPacked(Unpacked(ct, uint64(tc.Halfslots()+pasta.T)), decryptor, encoder)
 			decrypted2 = decrypted2[tc.Halfslots():]
 			if !util.EqualSlices(decrypted2, toVec(s2)) {
 				t.Errorf("bfv AddRc is not the same as pasta AddRc")
@@ -142,7 +142,7 @@ func TestUtil(t *testing.T) {
 
 		t.Run("TestUtil_Mix", func(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -164,7 +164,7 @@ func TestUtil(t *testing.T) {
 			ct = Mix(ct, evaluator, encoder)
 
 			stateAfterMix := toVec(pastaUtil.State())
-			decrypted := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			decrypted := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(decrypted, stateAfterMix) {
 				t.Errorf("bfv Mix is not the same as pasta Mix")
 			}
@@ -173,7 +173,7 @@ func TestUtil(t *testing.T) {
 		t.Run("TestUtil_SboxCube", func(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
 			pastaUtil2, _ := newPastaUtil(tc.modulus)
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -195,12 +195,12 @@ func TestUtil(t *testing.T) {
 			pastaUtil2.SboxCube(s2)
 			ct = SboxCube(ct, evaluator)
 
-			decrypted := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			decrypted := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(decrypted, toVec(s1)) {
 				t.Errorf("bfv SCube is not the same as pasta SCube")
 			}
 
-			decrypted2 := DecryptPacked(ct, uint64(tc.Halfslots()+pasta.T), decryptor, encoder)
+			decrypted2 := DecryptPacked(Unpacked(ct, uint64(tc.Halfslots()+pasta.T)), decryptor, encoder)
 			decrypted2 = decrypted2[tc.Halfslots():]
 			if !util.EqualSlices(decrypted2, toVec(s2)) {
 				t.Errorf("bfv SCube is not the same as pasta SCube")
@@ -210,7 +210,7 @@ func TestUtil(t *testing.T) {
 		t.Run("TestUtil_SboxFeistel", func(t *testing.T) {
 			pastaUtil, _ := newPastaUtil(tc.modulus)
 			pastaUtil2, _ := newPastaUtil(tc.modulus)
-			encryptor, decryptor, evaluator, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, evaluator, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			s1 := testVec()
 			s2 := testVec2()
@@ -232,12 +232,12 @@ func TestUtil(t *testing.T) {
 			pastaUtil.SboxFeistel(s1)
 			pastaUtil2.SboxFeistel(s2)
 
-			decrypted := DecryptPacked(ct, uint64(len(s1)), decryptor, encoder)
+			decrypted := DecryptPacked(Unpacked(ct, uint64(len(s1))), decryptor, encoder)
 			if !util.EqualSlices(decrypted, toVec(s1)) {
 				t.Errorf("bfv SFeistel is not the same as pasta SFeistel")
 			}
 
-			decrypted2 := DecryptPacked(ct, uint64(tc.Halfslots()+pasta.T), decryptor, encoder)
+			decrypted2 := DecryptPacked(Unpacked(ct, uint64(tc.Halfslots()+pasta.T)), decryptor, encoder)
 			decrypted2 = decrypted2[tc.Halfslots():]
 			if !util.EqualSlices(decrypted2, toVec(s2)) {
 				t.Errorf("bfv SFeistel is not the same as pasta SFeistel")
@@ -245,7 +245,7 @@ func TestUtil(t *testing.T) {
 		})
 
 		t.Run("TestUtil_BasicBFVDecrypt", func(t *testing.T) {
-			encryptor, decryptor, _, encoder, bfv, _ := NewBFV(tc.modulus, tc.bfvDegree)
+			encryptor, decryptor, _, encoder, bfv, _ := newBFV(tc.modulus, tc.bfvDegree)
 
 			vec := testVec()
 
@@ -253,7 +253,7 @@ func TestUtil(t *testing.T) {
 			pt := bfv2.NewPlaintext(bfv.Params, bfv.Params.MaxLevel())
 			encoder.Encode(toVec(vec), pt)
 			ct := encryptor.EncryptNew(pt)
-			d := DecryptPacked(ct, uint64(len(vec)), decryptor, encoder)
+			d := DecryptPacked(Unpacked(ct, uint64(len(vec))), decryptor, encoder)
 			if !util.EqualSlices(d, toVec(vec)) {
 				t.Errorf("not equal slices")
 			}
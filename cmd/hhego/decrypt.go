@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+)
+
+func runDecrypt(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	skPath := fs.String("sk", bfvSecretKeyFile, "bfv secret key file or client bundle")
+	inPath := fs.String("in", "", "bfv ciphertext file")
+	size := fs.Uint64("n", 0, "number of slots to output, 0 outputs every slot")
+	outPath := fs.String("out", "", "optional plaintext output file, values are printed otherwise")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inPath == "" {
+		return errors.New("-in is required")
+	}
+
+	skFile, sk, err := readSecretKey(*skPath)
+	if err != nil {
+		return err
+	}
+	ctFile, params, err := readWithParams(*inPath, "")
+	if err != nil {
+		return err
+	}
+	if err := sameParams(skFile, ctFile); err != nil {
+		return err
+	}
+	decryptor, encoder := bfv.NewDecryptor(params, sk), bfv.NewEncoder(params)
+
+	var decrypted []uint64
+	switch ctFile.Kind {
+	case util.KindBfvCiphertext, util.KindPastaSecretKeyCt:
+		_, ct, err := readCiphertext(*inPath, ctFile.Kind)
+		if err != nil {
+			return err
+		}
+		decrypted = hhegobfv.DecryptPacked(hhegobfv.Unpacked(ct, uint64(params.N())), decryptor, encoder)
+	case util.KindBfvPackedCiphertexts:
+		_, packed, err := readPackedCiphertexts(*inPath)
+		if err != nil {
+			return err
+		}
+		decrypted = hhegobfv.DecryptPacked(packed, decryptor, encoder)
+	default:
+		return fmt.Errorf("%s: expected a bfv ciphertext, got %s", *inPath, ctFile.Kind)
+	}
+	if *size != 0 && *size < uint64(len(decrypted)) {
+		decrypted = decrypted[:*size]
+	}
+
+	if *outPath != "" {
+		err := writeValues(*outPath, util.KindPlaintext, ctFile.Degree, ctFile.Modulus, decrypted, secretPerm)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, *outPath)
+
+		return nil
+	}
+	fmt.Fprintln(out, formatValues(decrypted))
+
+	return nil
+}
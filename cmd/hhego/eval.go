@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+var evalOps = map[string]int{
+	"add": util.Add,
+	"sub": util.Sub,
+	"mul": util.Mul,
+}
+
+func runEval(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	op := fs.String("op", "", "operation (add, sub or mul)")
+	aPath := fs.String("a", "", "first operand bfv ciphertext file")
+	bPath := fs.String("b", "", "second operand bfv ciphertext file")
+	evkPath := fs.String("evk", "", "evaluation or relinearization key file, needed by mul")
+	outPath := fs.String("out", "", "output bfv ciphertext file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opType, ok := evalOps[*op]
+	if !ok {
+		return fmt.Errorf("unknown operation %q", *op)
+	}
+	if *aPath == "" || *bPath == "" || *outPath == "" {
+		return errors.New("-a, -b and -out are required")
+	}
+	if opType == util.Mul && *evkPath == "" {
+		return errors.New("mul needs a relinearization key (-evk)")
+	}
+
+	aFile, a, err := readCiphertext(*aPath, util.KindBfvCiphertext)
+	if err != nil {
+		return err
+	}
+	bFile, b, err := readCiphertext(*bPath, util.KindBfvCiphertext)
+	if err != nil {
+		return err
+	}
+
+	evk := rlwe.NewEvaluationKeySet()
+	files := []util.File{aFile, bFile}
+	if *evkPath != "" {
+		var evkFile util.File
+		if evkFile, evk, err = readEvaluationKeys(*evkPath); err != nil {
+			return err
+		}
+		files = append(files, evkFile)
+	}
+	if err := sameParams(files...); err != nil {
+		return err
+	}
+
+	params, err := paramsOf(aFile)
+	if err != nil {
+		return err
+	}
+	res := util.ExecuteOp(bfv.NewEvaluator(params, evk), a, b, opType)
+
+	if err := writeObject(*outPath, util.KindBfvCiphertext, params, res, publicPerm); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, *outPath)
+
+	return nil
+}
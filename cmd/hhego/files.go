@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// secret material is only readable by its owner
+const (
+	secretPerm = os.FileMode(0600)
+	publicPerm = os.FileMode(0644)
+)
+
+// passphraseEnv holds the passphrase of sealed client bundles
+const passphraseEnv = "HHEGO_PASSPHRASE"
+
+func passphrase() []byte {
+	return []byte(os.Getenv(passphraseEnv))
+}
+
+func checkDegree(degree uint64) error {
+	switch degree {
+	case 1 << 14, 1 << 15, 1 << 16:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported polynomial degree %d (use 16384, 32768 or 65536)", degree)
+}
+
+// checkModulus checks that modulus is a plaintext modulus bfv accepts at degree,
+// a prime congruent to 1 mod 2*degree
+func checkModulus(degree, modulus uint64) error {
+	_, err := hhegobfv.NewBfvParams(modulus, degree)
+
+	return err
+}
+
+func paramsOf(f util.File) (bfv.Parameters, error) {
+	if err := checkDegree(f.Degree); err != nil {
+		return bfv.Parameters{}, err
+	}
+
+	return hhegobfv.NewBfvParams(f.Modulus, f.Degree)
+}
+
+// sameParams checks that every file was produced under the same bfv parameters
+func sameParams(files ...util.File) error {
+	for _, f := range files[1:] {
+		if f.Degree != files[0].Degree || f.Modulus != files[0].Modulus {
+			return fmt.Errorf("parameter mismatch: %s is (N=%d, T=%d) but %s is (N=%d, T=%d)",
+				f.Kind, f.Degree, f.Modulus, files[0].Kind, files[0].Degree, files[0].Modulus)
+		}
+	}
+
+	return nil
+}
+
+func writeObject(path, kind string, params bfv.Parameters, obj encoding.BinaryMarshaler,
+	perm os.FileMode) error {
+	payload, err := obj.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(path, util.NewFile(kind, uint64(params.N()), params.T(), payload), perm)
+}
+
+func writeValues(path, kind string, degree, modulus uint64, v []uint64, perm os.FileMode) error {
+	payload, _ := values(v).MarshalBinary()
+
+	return util.WriteFile(path, util.NewFile(kind, degree, modulus, payload), perm)
+}
+
+// values is a plain uint64 list, stored big endian as util.BytesToUint64Array expects
+type values []uint64
+
+func (v values) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := binary.Write(&buf, binary.BigEndian, []uint64(v))
+
+	return buf.Bytes(), err
+}
+
+func readValues(path, kind string) (util.File, []uint64, error) {
+	f, err := util.ReadFile(path, kind)
+	if err != nil {
+		return f, nil, err
+	}
+	if len(f.Payload)%8 != 0 {
+		return f, nil, fmt.Errorf("%s: malformed value list", path)
+	}
+
+	return f, util.BytesToUint64Array(f.Payload), nil
+}
+
+// readPastaKey accepts either a pasta key file or a client bundle
+func readPastaKey(path string) (util.File, []uint64, error) {
+	f, err := util.ReadFile(path, "")
+	if err != nil {
+		return f, nil, err
+	}
+
+	if isClientBundle(f) {
+		c, err := keystore.ReadClientBundle(path, passphrase())
+		if err != nil {
+			return f, nil, err
+		}
+
+		return f, c.PastaKey, nil
+	}
+
+	return readValues(path, util.KindPastaSecretKey)
+}
+
+// readSecretKey accepts either a bfv secret key file or a client bundle
+func readSecretKey(path string) (util.File, *rlwe.SecretKey, error) {
+	f, params, err := readWithParams(path, "")
+	if err != nil {
+		return f, nil, err
+	}
+
+	switch {
+	case isClientBundle(f):
+		c, err := keystore.ReadClientBundle(path, passphrase())
+		if err != nil {
+			return f, nil, err
+		}
+
+		return f, c.SecretKey, nil
+	case f.Kind != util.KindBfvSecretKey:
+		return f, nil, fmt.Errorf("%s: expected a bfv secret key, got %s", path, f.Kind)
+	}
+
+	sk := rlwe.NewSecretKey(params.Parameters)
+
+	return f, sk, unmarshal(path, sk, f.Payload)
+}
+
+// readEvaluationKeys accepts a full evaluation key set, a bare
+// relinearization key or a server bundle
+func readEvaluationKeys(path string) (util.File, *rlwe.EvaluationKeySet, error) {
+	f, params, err := readWithParams(path, "")
+	if err != nil {
+		return f, nil, err
+	}
+
+	evk := rlwe.NewEvaluationKeySet()
+	switch f.Kind {
+	case util.KindBfvEvaluationKeys:
+		err = unmarshal(path, evk, f.Payload)
+	case util.KindBfvRelinKey:
+		evk.RelinearizationKey = rlwe.NewRelinearizationKey(params.Parameters)
+		err = unmarshal(path, evk.RelinearizationKey, f.Payload)
+	case util.KindServerBundle:
+		var s *keystore.ServerBundle
+		if s, err = keystore.ReadServerBundle(path); err == nil {
+			evk = s.EvaluationKeys
+		}
+	default:
+		err = fmt.Errorf("%s: expected evaluation keys, got %s", path, f.Kind)
+	}
+
+	return f, evk, err
+}
+
+// readPastaKeyCt accepts either a bfv encrypted pasta key or a server bundle
+func readPastaKeyCt(path string) (util.File, *rlwe.Ciphertext, error) {
+	f, err := util.ReadFile(path, "")
+	if err != nil {
+		return f, nil, err
+	}
+
+	if f.Kind == util.KindServerBundle {
+		s, err := keystore.ReadServerBundle(path)
+		if err != nil {
+			return f, nil, err
+		}
+
+		return f, s.PastaKeyCt, nil
+	}
+
+	return readCiphertext(path, util.KindPastaSecretKeyCt)
+}
+
+func readCiphertext(path, kind string) (util.File, *rlwe.Ciphertext, error) {
+	f, params, err := readWithParams(path, kind)
+	if err != nil {
+		return f, nil, err
+	}
+	ct := bfv.NewCiphertext(params, 1, params.MaxLevel())
+
+	return f, ct, unmarshal(path, ct, f.Payload)
+}
+
+// packedCiphertexts is a transciphered message spanning several ciphertexts,
+// see hhegobfv.MarshalPackedCiphertexts
+type packedCiphertexts []hhegobfv.PackedCiphertext
+
+func (p packedCiphertexts) MarshalBinary() ([]byte, error) {
+	return hhegobfv.MarshalPackedCiphertexts(p)
+}
+
+func readPackedCiphertexts(path string) (util.File, []hhegobfv.PackedCiphertext, error) {
+	f, params, err := readWithParams(path, util.KindBfvPackedCiphertexts)
+	if err != nil {
+		return f, nil, err
+	}
+
+	packed, err := hhegobfv.UnmarshalPackedCiphertexts(f.Payload, params)
+	if err != nil {
+		return f, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return f, packed, nil
+}
+
+func isClientBundle(f util.File) bool {
+	return f.Kind == util.KindClientBundle || f.Kind == util.KindClientBundleSealed
+}
+
+func readWithParams(path, kind string) (util.File, bfv.Parameters, error) {
+	f, err := util.ReadFile(path, kind)
+	if err != nil {
+		return f, bfv.Parameters{}, err
+	}
+	params, err := paramsOf(f)
+
+	return f, params, err
+}
+
+func unmarshal(path string, obj encoding.BinaryUnmarshaler, data []byte) error {
+	if err := obj.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// parseValues parses a comma separated list of unsigned integers
+func parseValues(s string, modulus uint64) ([]uint64, error) {
+	var vals []uint64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(field, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", field)
+		}
+		if v >= modulus {
+			return nil, fmt.Errorf("value %d doesn't fit the plaintext modulus %d", v, modulus)
+		}
+		vals = append(vals, v)
+	}
+
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("no values given")
+	}
+
+	return vals, nil
+}
+
+func formatValues(vals []uint64) string {
+	s := make([]string, len(vals))
+	for i, v := range vals {
+		s[i] = strconv.FormatUint(v, 10)
+	}
+
+	return strings.Join(s, ",")
+}
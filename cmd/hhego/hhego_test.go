@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+)
+
+func run(t *testing.T, cmd func([]string, io.Writer) error, args ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := cmd(args, &out); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+func TestHheFlow(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	secretPath := func(name string) string { return filepath.Join(dir, secretDirName, name) }
+
+	// a secret key left readable by others gets its mode fixed
+	if err := os.MkdirAll(filepath.Join(dir, secretDirName), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secretPath(bfvSecretKeyFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, runKeygen, "-degree", "32768", "-modulus", "65537", "-length", "4", "-out", dir)
+	for _, name := range []string{bfvSecretKeyFile, pastaSecretKeyFile} {
+		info, err := os.Stat(secretPath(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0077 != 0 {
+			t.Errorf("%s shouldn't be readable by others, mode %v", name, info.Mode())
+		}
+		if _, err := os.Stat(path(name)); err == nil {
+			t.Errorf("%s was written next to the public keys", name)
+		}
+	}
+
+	run(t, runPastaEncrypt, "-key", secretPath(pastaSecretKeyFile), "-values", "0,1,0,0", "-out", path("vote.pasta"))
+	run(t, runTranscipher, "-in", path("vote.pasta"), "-key", path(pastaSecretKeyCtFile),
+		"-evk", path(bfvEvaluationKeysFile), "-out", path("vote.ct"))
+
+	decrypted := run(t, runDecrypt, "-sk", secretPath(bfvSecretKeyFile), "-in", path("vote.ct"), "-n", "4")
+	if decrypted != "0,1,0,0" {
+		t.Errorf("transciphered vote decrypts to %s", decrypted)
+	}
+
+	run(t, runEval, "-op", "add", "-a", path("vote.ct"), "-b", path("vote.ct"), "-out", path("sum.ct"))
+	run(t, runEval, "-op", "mul", "-a", path("sum.ct"), "-b", path("sum.ct"),
+		"-evk", path(bfvRelinKeyFile), "-out", path("prod.ct"))
+	decrypted = run(t, runDecrypt, "-sk", secretPath(bfvSecretKeyFile), "-in", path("prod.ct"), "-n", "4")
+	if decrypted != "0,4,0,0" {
+		t.Errorf("(2v)^2 decrypts to %s", decrypted)
+	}
+
+	info := run(t, runInspect, path("vote.pasta"), path(bfvEvaluationKeysFile))
+	for _, want := range []string{"kind:     " + util.KindPastaCiphertext, "values:   4",
+		"kind:     " + util.KindBfvEvaluationKeys, "relinearization key: true"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("inspect output is missing %q:\n%s", want, info)
+		}
+	}
+
+	// files can't be mixed up
+	var out bytes.Buffer
+	if err := runDecrypt([]string{"-sk", path(bfvPublicKeyFile), "-in", path("vote.ct")}, &out); err == nil {
+		t.Errorf("decrypt should reject a public key")
+	}
+}
+
+func TestKeygenArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"-degree", "1000"},
+		{"-modulus", "65536"},
+		{"-degree", "65536", "-modulus", "65537"},
+		{"-length", "0"},
+	} {
+		if err := runKeygen(append(args, "-out", dir), io.Discard); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+
+	// nothing is written on bad arguments
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("keygen wrote %d files on bad arguments", len(entries))
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	f := util.NewFile(util.KindPlaintext, 16384, 65537, []byte{1, 2, 3})
+	data, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g util.File
+	if err := g.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if g.Version != util.FileVersion || g.Kind != f.Kind || g.Degree != f.Degree || g.Modulus != f.Modulus ||
+		!bytes.Equal(g.Payload, f.Payload) {
+		t.Errorf("round trip changed the file: %+v", g)
+	}
+
+	if err := g.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Errorf("expected an error on truncated files")
+	}
+	data[4] = 0xff
+	if err := g.UnmarshalBinary(data); err == nil {
+		t.Errorf("expected an error on unknown versions")
+	}
+}
+
+func TestBundles(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	t.Setenv(passphraseEnv, "correct horse")
+
+	run(t, runKeygen, "-degree", "16384", "-length", "4", "-bundles", "-seal", "-out", dir, "-secret-out", dir)
+	run(t, runPastaEncrypt, "-key", path(clientBundleFile), "-values", "0,1,0,0", "-out", path("vote.pasta"))
+
+	info := run(t, runInspect, path(clientBundleFile), path(serverBundleFile))
+	for _, want := range []string{util.KindClientBundleSealed, util.KindServerBundle} {
+		if !strings.Contains(info, want) {
+			t.Errorf("inspect output is missing %q:\n%s", want, info)
+		}
+	}
+
+	t.Setenv(passphraseEnv, "")
+	var out bytes.Buffer
+	err := runPastaEncrypt([]string{"-key", path(clientBundleFile), "-values", "1", "-out", path("x.pasta")}, &out)
+	if err == nil {
+		t.Errorf("a sealed bundle shouldn't open without passphrase")
+	}
+}
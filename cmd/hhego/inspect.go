@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fedejinich/hhego/util"
+)
+
+func runInspect(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: hhego inspect <file>...")
+	}
+
+	for i, path := range fs.Args() {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := inspect(path, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func inspect(path string, out io.Writer) error {
+	f, err := util.ReadFile(path, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "file:     %s\n", path)
+	fmt.Fprintf(out, "version:  %d\n", f.Version)
+	fmt.Fprintf(out, "kind:     %s\n", f.Kind)
+	fmt.Fprintf(out, "degree:   %d\n", f.Degree)
+	fmt.Fprintf(out, "modulus:  %d\n", f.Modulus)
+	fmt.Fprintf(out, "payload:  %d bytes\n", len(f.Payload))
+
+	switch f.Kind {
+	case util.KindBfvCiphertext, util.KindPastaSecretKeyCt:
+		_, ct, err := readCiphertext(path, f.Kind)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "ct degree: %d\n", ct.Degree())
+		fmt.Fprintf(out, "level:    %d\n", ct.Level())
+	case util.KindBfvPackedCiphertexts:
+		_, packed, err := readPackedCiphertexts(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "ciphertexts: %d\n", len(packed))
+		for _, p := range packed {
+			fmt.Fprintf(out, "  elements [%d, %d), %d in the first row\n", p.Start, p.End, p.RowLength)
+		}
+	case util.KindBfvEvaluationKeys:
+		_, evk, err := readEvaluationKeys(path)
+		if err != nil {
+			return err
+		}
+		galEls := evk.GetGaloisKeysList()
+		sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+		fmt.Fprintf(out, "relinearization key: %t\n", evk.RelinearizationKey != nil)
+		fmt.Fprintf(out, "galois keys: %d %v\n", len(galEls), galEls)
+	case util.KindPastaCiphertext, util.KindPlaintext:
+		_, vals, err := readValues(path, f.Kind)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "values:   %d [%s]\n", len(vals), formatValues(vals))
+	case util.KindPastaSecretKey:
+		fmt.Fprintf(out, "values:   %d (secret, not shown)\n", len(f.Payload)/8)
+	}
+
+	return nil
+}
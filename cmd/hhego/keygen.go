@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+)
+
+// file names written by keygen
+const (
+	bfvSecretKeyFile      = "bfv.sk"
+	bfvPublicKeyFile      = "bfv.pk"
+	bfvRelinKeyFile       = "bfv.rlk"
+	bfvEvaluationKeysFile = "bfv.evk"
+	pastaSecretKeyFile    = "pasta.sk"
+	pastaSecretKeyCtFile  = "pasta.sk.ct"
+
+	clientBundleFile = "client.bundle"
+	serverBundleFile = "server.bundle"
+
+	// secretDirName is where keygen writes the secret keys by default, so the
+	// public ones can be shipped as a whole directory
+	secretDirName = "secret"
+)
+
+func runKeygen(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	degree := fs.Uint64("degree", 32768, "bfv polynomial degree (16384, 32768 or 65536)")
+	modulus := fs.Uint64("modulus", 65537, "bfv plaintext modulus, also used as pasta modulus")
+	length := fs.Uint64("length", pasta.CiphertextSize, "max message length to transcipher, sets the rotation keys")
+	dir := fs.String("out", ".", "output directory")
+	secretDir := fs.String("secret-out", "", "output directory of the secret keys, <out>/"+secretDirName+
+		" by default")
+	bundles := fs.Bool("bundles", false, "write a client and a server bundle instead of one file per key")
+	seal := fs.Bool("seal", false, "seal the client bundle with the passphrase in $"+passphraseEnv)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := checkDegree(*degree); err != nil {
+		return err
+	}
+	if err := checkModulus(*degree, *modulus); err != nil {
+		return err
+	}
+	if *length == 0 || *length > *degree/2 {
+		return fmt.Errorf("message length must be between 1 and %d", *degree/2)
+	}
+	if *seal && (!*bundles || len(passphrase()) == 0) {
+		return errors.New("-seal needs -bundles and a passphrase in $" + passphraseEnv)
+	}
+	if *secretDir == "" {
+		*secretDir = filepath.Join(*dir, secretDirName)
+	}
+	for _, d := range []string{*dir, *secretDir} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return err
+		}
+	}
+
+	client, server, err := keystore.Generate(*degree, *modulus, *length)
+	if err != nil {
+		return err
+	}
+
+	if *bundles {
+		clientPath := filepath.Join(*secretDir, clientBundleFile)
+		var secret []byte
+		if *seal {
+			secret = passphrase()
+		}
+		if err := keystore.WriteClientBundle(clientPath, client, secret); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, clientPath)
+
+		serverPath := filepath.Join(*dir, serverBundleFile)
+		if err := keystore.WriteServerBundle(serverPath, server); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, serverPath)
+
+		return nil
+	}
+
+	params := client.Params()
+	writes := []struct {
+		dir  string
+		name string
+		kind string
+		obj  encoding.BinaryMarshaler
+		perm os.FileMode
+	}{
+		{*secretDir, bfvSecretKeyFile, util.KindBfvSecretKey, client.SecretKey, secretPerm},
+		{*dir, bfvPublicKeyFile, util.KindBfvPublicKey, server.PublicKey, publicPerm},
+		{*dir, bfvRelinKeyFile, util.KindBfvRelinKey, server.EvaluationKeys.RelinearizationKey, publicPerm},
+		{*dir, bfvEvaluationKeysFile, util.KindBfvEvaluationKeys, server.EvaluationKeys, publicPerm},
+		{*secretDir, pastaSecretKeyFile, util.KindPastaSecretKey, values(client.PastaKey), secretPerm},
+		{*dir, pastaSecretKeyCtFile, util.KindPastaSecretKeyCt, server.PastaKeyCt, publicPerm},
+	}
+	for _, w := range writes {
+		path := filepath.Join(w.dir, w.name)
+		if err := writeObject(path, w.kind, params, w.obj, w.perm); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fedejinich/hhego/util"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, out io.Writer) error
+}
+
+var commands = []command{
+	{"keygen", "generate bfv keys and a pasta key", runKeygen},
+	{"pasta-encrypt", "encrypt a message with pasta", runPastaEncrypt},
+	{"transcipher", "turn a pasta ciphertext into a bfv ciphertext", runTranscipher},
+	{"eval", "add, sub or mul two bfv ciphertexts", runEval},
+	{"decrypt", "decrypt a bfv ciphertext", runDecrypt},
+	{"inspect", "print the header and contents summary of hhego files", runInspect},
+	{"noiseprof", "profile noise growth along an operation script", runNoiseprof},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		// keep stdout for the command output, progress goes to stderr
+		util.Progress = os.Stderr
+
+		if err := c.run(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "hhego %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "hhego: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: hhego <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.usage)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+const (
+	opAdd         = "add"
+	opMul         = "mul"
+	opRotate      = "rotate"
+	opTranscipher = "transcipher"
+)
+
+// scriptOp is one line of a noiseprof script, op repeated count times
+type scriptOp struct {
+	op    string
+	count int
+}
+
+// noiseStep is a row of the noise profile, measured right after an operation
+type noiseStep struct {
+	Step       int     `json:"step"`
+	Op         string  `json:"op"`
+	StdBits    float64 `json:"stdBits"`
+	MaxBits    float64 `json:"maxBits"`
+	BudgetBits float64 `json:"budgetBits"`
+	Decrypts   bool    `json:"decrypts"`
+}
+
+type noiseProfile struct {
+	Degree     uint64      `json:"degree"`
+	Modulus    uint64      `json:"modulus"`
+	NoiseBound float64     `json:"noiseBound"`
+	Steps      []noiseStep `json:"steps"`
+	FailedStep *int        `json:"failedStep"`
+}
+
+func runNoiseprof(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("noiseprof", flag.ContinueOnError)
+	degree := fs.Uint64("degree", 32768, "bfv polynomial degree (16384, 32768 or 65536)")
+	modulus := fs.Uint64("modulus", 65537, "bfv plaintext modulus")
+	scriptPath := fs.String("script", "", "operation script file, '-' reads stdin")
+	ops := fs.String("ops", "", "inline operation script, e.g. 'add:290' or 'mul:3,rotate:10'")
+	length := fs.Uint64("length", 16, "message length used by transcipher steps")
+	format := fs.String("format", "csv", "output format (csv or json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := checkDegree(*degree); err != nil {
+		return err
+	}
+	if err := checkModulus(*degree, *modulus); err != nil {
+		return err
+	}
+	if *length == 0 || *length > *degree/2 {
+		return fmt.Errorf("message length must be between 1 and %d", *degree/2)
+	}
+
+	script, err := readScript(*scriptPath, *ops)
+	if err != nil {
+		return err
+	}
+
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	profile := profileNoise(script, *degree, *modulus, *length)
+
+	if profile.FailedStep != nil {
+		step := profile.Steps[*profile.FailedStep]
+		fmt.Fprintf(os.Stderr, "decryption first failed at step %d (%s)\n", step.Step, step.Op)
+	}
+
+	if *format == "json" {
+		return writeProfileJSON(out, profile)
+	}
+
+	return writeProfileCSV(out, profile)
+}
+
+func readScript(path, inline string) ([]scriptOp, error) {
+	switch {
+	case path != "" && inline != "":
+		return nil, errors.New("use either -script or -ops, not both")
+	case inline != "":
+		return parseScript(strings.NewReader(inline))
+	case path == "-":
+		return parseScript(os.Stdin)
+	case path != "":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return parseScript(f)
+	}
+
+	return nil, errors.New("missing operation script (-script or -ops)")
+}
+
+// parseScript reads operations separated by newlines or commas. Each operation
+// is written as 'op', 'op:count' or 'op count', and '#' starts a comment.
+func parseScript(r io.Reader) ([]scriptOp, error) {
+	var script []scriptOp
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+
+		for _, token := range strings.Split(text, ",") {
+			fields := strings.Fields(strings.Replace(token, ":", " ", 1))
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) > 2 {
+				return nil, fmt.Errorf("line %d: malformed operation %q", line, strings.TrimSpace(token))
+			}
+
+			op := strings.ToLower(fields[0])
+			switch op {
+			case opAdd, opMul, opRotate, opTranscipher:
+			default:
+				return nil, fmt.Errorf("line %d: unknown operation %q", line, fields[0])
+			}
+
+			count := 1
+			if len(fields) == 2 {
+				c, err := strconv.Atoi(fields[1])
+				if err != nil || c < 1 {
+					return nil, fmt.Errorf("line %d: invalid count %q", line, fields[1])
+				}
+				count = c
+			}
+
+			script = append(script, scriptOp{op, count})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(script) == 0 {
+		return nil, errors.New("empty operation script")
+	}
+
+	return script, nil
+}
+
+// noiseProfiler keeps a ciphertext together with the plaintext it should
+// decrypt to. Slots whose value is unknown (e.g. past the transciphered
+// message) are not checked.
+type noiseProfiler struct {
+	params    bfv.Parameters
+	encoder   bfv.Encoder
+	encryptor rlwe.Encryptor
+	decryptor rlwe.Decryptor
+	evaluator bfv.Evaluator
+
+	pastaKey   []uint64
+	pastaKeyCt *rlwe.Ciphertext
+	length     uint64
+
+	ct       *rlwe.Ciphertext
+	expected []uint64
+	known    []bool
+}
+
+func newNoiseProfiler(script []scriptOp, degree, modulus, length uint64) *noiseProfiler {
+	params := hhegobfv.GenerateBfvParams(modulus, degree)
+	keygen := rlwe.NewKeyGenerator(params.Parameters)
+	sk, pk := keygen.GenKeyPairNew()
+	rk := keygen.GenRelinearizationKeyNew(sk)
+
+	p := &noiseProfiler{params: params, length: length}
+
+	var evk rlwe.EvaluationKeySet
+	if usesTranscipher(script) {
+		p.encryptor, p.decryptor, _, p.encoder, _, evk = hhegobfv.NewBFVPasta(degree, pasta.DefaultSecLevel,
+			length, modulus, sk, rk)
+		p.pastaKey = hhegobfv.RandomInputV(pasta.SecretKeySize, modulus)
+		p.pastaKeyCt = hhegobfv.EncryptPastaSecretKey(p.pastaKey, p.encoder, p.encryptor, params)
+	} else {
+		p.encryptor = bfv.NewEncryptor(params, pk)
+		p.decryptor = bfv.NewDecryptor(params, sk)
+		p.encoder = bfv.NewEncoder(params)
+		evk = *rlwe.NewEvaluationKeySet()
+		evk.RelinearizationKey = rk
+	}
+
+	// rotate steps always move the columns by one
+	galEl := params.GaloisElementForColumnRotationBy(1)
+	if _, ok := evk.GaloisKeys[galEl]; !ok {
+		evk.GaloisKeys[galEl] = keygen.GenGaloisKeyNew(galEl, sk)
+	}
+	p.evaluator = bfv.NewEvaluator(params, &evk)
+
+	// start from a fresh encryption of a fully known vector
+	p.expected = hhegobfv.RandomInputV(params.N(), modulus)
+	p.known = make([]bool, params.N())
+	for i := range p.known {
+		p.known[i] = true
+	}
+	pt := bfv.NewPlaintext(params, params.MaxLevel())
+	p.encoder.Encode(p.expected, pt)
+	p.ct = p.encryptor.EncryptNew(pt)
+
+	return p
+}
+
+func usesTranscipher(script []scriptOp) bool {
+	for _, s := range script {
+		if s.op == opTranscipher {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *noiseProfiler) apply(op string) {
+	t := p.params.T()
+
+	switch op {
+	case opAdd:
+		p.evaluator.Add(p.ct, p.ct, p.ct)
+		for i, v := range p.expected {
+			p.expected[i] = (v + v) % t
+		}
+	case opMul:
+		p.ct = p.evaluator.MulRelinNew(p.ct, p.ct)
+		for i, v := range p.expected {
+			hi, lo := bits.Mul64(v, v)
+			p.expected[i] = bits.Rem64(hi, lo, t)
+		}
+	case opRotate:
+		p.ct = p.evaluator.RotateColumnsNew(p.ct, 1)
+		p.rotateExpected()
+	case opTranscipher:
+		// transcipher the first length slots, everything else becomes unknown
+		message := make([]uint64, p.length)
+		copy(message, p.expected)
+		pastaCipher := pasta.NewPasta(p.pastaKey, t, pastaParams)
+		// only the first ciphertext is profiled if the message doesn't fit in one
+		packed := hhegobfv.Transcipher(pastaCipher.Encrypt(message), p.pastaKeyCt, pastaParams,
+			pasta.DefaultSecLevel, p.encoder, p.evaluator, p.params)
+		p.ct = packed[0].Ciphertext
+
+		for i := range p.known {
+			p.known[i] = uint64(i) < packed[0].End
+		}
+	}
+}
+
+// rotateExpected mirrors a column rotation by one, each row of N/2 slots
+// rotates independently
+func (p *noiseProfiler) rotateExpected() {
+	halfslots := uint64(len(p.expected) / 2)
+	for _, start := range []uint64{0, halfslots} {
+		end := start + halfslots
+
+		util.Rotate(p.expected, start, start+1, end)
+
+		first := p.known[start]
+		copy(p.known[start:end], p.known[start+1:end])
+		p.known[end-1] = first
+	}
+}
+
+func (p *noiseProfiler) measure(step int, op string) noiseStep {
+	std, _, max := hhegobfv.NoiseStats(p.decryptor, p.encoder, p.evaluator, p.ct)
+
+	decrypted := p.encoder.DecodeUintNew(p.decryptor.DecryptNew(p.ct))
+	decrypts := true
+	for i, v := range p.expected {
+		if p.known[i] && decrypted[i] != v {
+			decrypts = false
+			break
+		}
+	}
+
+	return noiseStep{
+		Step:       step,
+		Op:         op,
+		StdBits:    std,
+		MaxBits:    max,
+		BudgetBits: hhegobfv.NoiseBound(p.params) - max,
+		Decrypts:   decrypts,
+	}
+}
+
+// profileNoise runs script on a fresh ciphertext and measures the noise after
+// every single operation. It stops at the first step that doesn't decrypt.
+func profileNoise(script []scriptOp, degree, modulus, length uint64) noiseProfile {
+	p := newNoiseProfiler(script, degree, modulus, length)
+
+	profile := noiseProfile{
+		Degree:     degree,
+		Modulus:    modulus,
+		NoiseBound: hhegobfv.NoiseBound(p.params),
+	}
+
+	profile.Steps = append(profile.Steps, p.measure(0, "encrypt"))
+
+	step := 0
+	for _, s := range script {
+		for i := 0; i < s.count; i++ {
+			step++
+			p.apply(s.op)
+
+			row := p.measure(step, s.op)
+			profile.Steps = append(profile.Steps, row)
+			if !row.Decrypts {
+				failed := len(profile.Steps) - 1
+				profile.FailedStep = &failed
+
+				return profile
+			}
+		}
+	}
+
+	return profile
+}
+
+func writeProfileCSV(out io.Writer, profile noiseProfile) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"step", "op", "std_bits", "max_bits", "budget_bits", "decrypts"}); err != nil {
+		return err
+	}
+
+	for _, s := range profile.Steps {
+		err := w.Write([]string{
+			strconv.Itoa(s.Step),
+			s.Op,
+			strconv.FormatFloat(s.StdBits, 'f', 2, 64),
+			strconv.FormatFloat(s.MaxBits, 'f', 2, 64),
+			strconv.FormatFloat(s.BudgetBits, 'f', 2, 64),
+			strconv.FormatBool(s.Decrypts),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func writeProfileJSON(out io.Writer, profile noiseProfile) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(profile)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	script, err := parseScript(strings.NewReader("# warm up\nadd 2\nmul:3, rotate\n\nTRANSCIPHER:1 # last"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []scriptOp{{opAdd, 2}, {opMul, 3}, {opRotate, 1}, {opTranscipher, 1}}
+	if len(script) != len(expected) {
+		t.Fatalf("expected %d operations, got %d", len(expected), len(script))
+	}
+	for i := range expected {
+		if script[i] != expected[i] {
+			t.Errorf("operation %d: expected %v, got %v", i, expected[i], script[i])
+		}
+	}
+
+	for _, bad := range []string{"", "# nothing", "div:2", "add:0", "add:x", "add 1 2"} {
+		if _, err := parseScript(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestProfileNoise(t *testing.T) {
+	script := []scriptOp{{opAdd, 2}, {opRotate, 2}, {opMul, 20}}
+	profile := profileNoise(script, uint64(math.Pow(2, 14)), 65537, 16)
+
+	if profile.FailedStep == nil {
+		t.Fatalf("expected decryption to fail within 20 multiplications")
+	}
+
+	failed := *profile.FailedStep
+	if len(profile.Steps) != failed+1 {
+		t.Errorf("profiling should stop at the failing step")
+	}
+	if failed <= 4 {
+		t.Errorf("additions and rotations shouldn't break decryption")
+	}
+
+	for _, s := range profile.Steps[:failed] {
+		if !s.Decrypts {
+			t.Errorf("step %d should decrypt", s.Step)
+		}
+		if s.BudgetBits <= 0 {
+			t.Errorf("step %d decrypts with no noise budget left", s.Step)
+		}
+	}
+	if profile.Steps[failed].Op != opMul {
+		t.Errorf("expected a multiplication to break decryption, got %s", profile.Steps[failed].Op)
+	}
+}
+
+func TestNoiseprofArgs(t *testing.T) {
+	for _, args := range [][]string{
+		{"-degree", "1000", "-ops", "add"},
+		{"-modulus", "65536", "-ops", "add"},
+		{"-degree", "65536", "-modulus", "65537", "-ops", "add"},
+		{"-length", "0", "-ops", "add"},
+	} {
+		if err := runNoiseprof(args, io.Discard); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
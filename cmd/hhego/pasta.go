@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+)
+
+var pastaParams = pasta.Params{
+	SecretKeySize:  pasta.SecretKeySize,
+	PlaintextSize:  pasta.PlaintextSize,
+	CiphertextSize: pasta.CiphertextSize,
+	Rounds:         pasta.Rounds,
+}
+
+func runPastaEncrypt(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("pasta-encrypt", flag.ContinueOnError)
+	keyPath := fs.String("key", pastaSecretKeyFile, "pasta secret key file or client bundle")
+	vals := fs.String("values", "", "comma separated message, e.g. 1,0,0,0")
+	outPath := fs.String("out", "", "output pasta ciphertext file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *vals == "" || *outPath == "" {
+		return errors.New("-values and -out are required")
+	}
+
+	keyFile, key, err := readPastaKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	if len(key) != pasta.SecretKeySize {
+		return fmt.Errorf("%s: expected %d key elements, got %d", *keyPath, pasta.SecretKeySize, len(key))
+	}
+
+	message, err := parseValues(*vals, keyFile.Modulus)
+	if err != nil {
+		return err
+	}
+
+	pastaCipher := pasta.NewPasta(key, keyFile.Modulus, pastaParams)
+	ciphertext := pastaCipher.Encrypt(message)
+
+	err = writeValues(*outPath, util.KindPastaCiphertext, keyFile.Degree, keyFile.Modulus, ciphertext, publicPerm)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, *outPath)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+)
+
+func runTranscipher(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("transcipher", flag.ContinueOnError)
+	inPath := fs.String("in", "", "pasta ciphertext file")
+	keyPath := fs.String("key", pastaSecretKeyCtFile, "bfv encrypted pasta secret key file")
+	evkPath := fs.String("evk", bfvEvaluationKeysFile, "bfv evaluation keys file")
+	serverPath := fs.String("server", "", "server bundle, replaces -key and -evk")
+	outPath := fs.String("out", "", "output bfv ciphertext file")
+	bothRows := fs.Bool("both-rows", false, "pack the output into both rows of slots, twice the elements per ciphertext")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inPath == "" || *outPath == "" {
+		return errors.New("-in and -out are required")
+	}
+	if *serverPath != "" {
+		*keyPath, *evkPath = *serverPath, *serverPath
+	}
+
+	msgFile, message, err := readValues(*inPath, util.KindPastaCiphertext)
+	if err != nil {
+		return err
+	}
+	keyFile, pastaSKCt, err := readPastaKeyCt(*keyPath)
+	if err != nil {
+		return err
+	}
+	evkFile, evk, err := readEvaluationKeys(*evkPath)
+	if err != nil {
+		return err
+	}
+	if err := sameParams(msgFile, keyFile, evkFile); err != nil {
+		return err
+	}
+
+	evaluator, encoder, p, err := hhegobfv.NewBFVPastaServer(msgFile.Degree, msgFile.Modulus, evk)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *evkPath, err)
+	}
+	// any matmul strategy the keys were generated for is fine
+	if err := hhegobfv.ValidateEvaluationKeys(p.Params, evk, uint64(len(message)), pasta.DefaultSecLevel,
+		hhegobfv.TranscipherOptions{Matmul: hhegobfv.MatmulAuto}); err != nil {
+		return fmt.Errorf("%s: %w", *evkPath, err)
+	}
+
+	opts := hhegobfv.TranscipherOptions{Matmul: hhegobfv.MatmulAuto}
+	if *bothRows {
+		opts.Packing = hhegobfv.PackBothRows
+	}
+	res, err := hhegobfv.TranscipherScratch(message, pastaSKCt, pastaParams, pasta.DefaultSecLevel, encoder,
+		evaluator, hhegobfv.NewScratch(p.Params), opts)
+	if err != nil {
+		return err
+	}
+
+	// a message that fits in the first row of one ciphertext is written as a
+	// plain one so it can be fed to eval
+	if len(res) == 1 && res[0].RowLength == res[0].End {
+		err = writeObject(*outPath, util.KindBfvCiphertext, p.Params, res[0].Ciphertext, publicPerm)
+	} else {
+		err = writeObject(*outPath, util.KindBfvPackedCiphertexts, p.Params, packedCiphertexts(res), publicPerm)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, *outPath)
+
+	return nil
+}
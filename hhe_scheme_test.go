@@ -73,12 +73,12 @@ func TestFoo(t *testing.T) {
 
 	// original bfv cipher
 	//encryptor, decryptor, evaluator, encoder, _, _ := hhegobfv.NewBFVPasta(uint64(params.N()), pasta.DefaultSecLevel,
-	//	uint64(len(op1)), 20, 10, params.T(), sk, rlk)
+	//	uint64(len(op1)), params.T(), sk, rlk)
 
 	// server side bfv cipher
 	//sk2, _ := keygen.GenKeyPairNew()
 	//_, _, ev, enc, _, _ := hhegobfv.NewBFVPasta(uint64(params.N()), pasta.DefaultSecLevel, uint64(len(op1)),
-	//	20, 10, params.T(), sk2, rlk)
+	//	params.T(), sk2, rlk)
 
 	//op1Pasta := pastaCipher.Encrypt(op1)
 	////op2Pasta := pastaCipher.Encrypt(op2)
@@ -204,12 +204,10 @@ func pastaSK() []uint64 {
 //	secLevel := 128
 //	messageLength := 200
 //	plaintext := hhegobfv.RandomInputV(messageLength, uint64(65537))
-//	bsgN1 := 20
-//	bsgN2 := 10
 //	useBsGs := true
 //
 //	hhetest(t, pastaSecretKey, plaintext, uint64(plainMod), uint64(modDegree), uint64(secLevel), uint64(messageLength),
-//		uint64(bsgN1), uint64(bsgN2), useBsGs)
+//		useBsGs)
 //}
 
 func TestHhe2(t *testing.T) {
@@ -252,12 +250,10 @@ func TestHhe2(t *testing.T) {
 	secLevel := 128
 	messageLength := 200
 	plaintext := hhegobfv.RandomInputV(messageLength, uint64(65537))
-	bsgN1 := 20
-	bsgN2 := 10
 	useBsGs := true
 
 	hhetest(t, pastaSecretKey, plaintext, uint64(plainMod), uint64(modDegree), uint64(secLevel), uint64(messageLength),
-		uint64(bsgN1), uint64(bsgN2), useBsGs)
+		useBsGs)
 }
 
 func TestHhe3(t *testing.T) {
@@ -321,11 +317,9 @@ func TestHhe3(t *testing.T) {
 	messageLength := 200
 	plaintext := hhegobfv.RandomInputV(messageLength, 8088322049)
 	useBsGs := true
-	bsgN1 := 20
-	bsgN2 := 10
 
 	hhetest(t, pastaSecretKey, plaintext, uint64(plainMod), uint64(modDegree), uint64(secLevel), uint64(messageLength),
-		uint64(bsgN1), uint64(bsgN2), useBsGs)
+		useBsGs)
 }
 
 //func TestHhe4(t *testing.T) {
@@ -423,15 +417,13 @@ func TestHhe3(t *testing.T) {
 //	secLevel := 128
 //	messageLength := 200
 //	useBsGs := true
-//	bsgN1 := 20
-//	bsgN2 := 10
 //	hhetest(t, pastaSecretKey, plaintext, uint64(plainMod), uint64(modDegree), uint64(secLevel), uint64(messageLength),
-//		uint64(bsgN1), uint64(bsgN2), useBsGs)
+//		useBsGs)
 //}
 
 // benchmark-testing for hhe scheme
-func hhetest(t *testing.T, pastaSecretKey, message []uint64, plainMod, polyDegree, secLevel, messageLength,
-	bsgN1, bsgN2 uint64, useBsGs bool) {
+func hhetest(t *testing.T, pastaSecretKey, message []uint64, plainMod, polyDegree, secLevel, messageLength uint64,
+	useBsGs bool) {
 
 	// create pasta cipher
 	pastaCipher := pasta.NewPasta(pastaSecretKey, plainMod, PastaParams)
@@ -442,7 +434,7 @@ func hhetest(t *testing.T, pastaSecretKey, message []uint64, plainMod, polyDegre
 	rk := keygen.GenRelinearizationKeyNew(sk)
 
 	// create bfv cipher
-	encryptor, decryptor, evaluator, encoder, _, _ := hhegobfv.NewBFVPasta(polyDegree, secLevel, messageLength, bsgN1, bsgN2, plainMod, sk, rk)
+	encryptor, decryptor, evaluator, encoder, _, _ := hhegobfv.NewBFVPasta(polyDegree, secLevel, messageLength, plainMod, sk, rk)
 
 	//bfv.printParameters()
 
@@ -462,7 +454,7 @@ func hhetest(t *testing.T, pastaSecretKey, message []uint64, plainMod, polyDegre
 	// bfv.printNoise()
 
 	// final decrypt
-	decrypted := hhegobfv.DecryptPacked(&bfvCiphertext, messageLength, decryptor, encoder)
+	decrypted := hhegobfv.DecryptPacked(bfvCiphertext, decryptor, encoder)
 
 	// bfv.printNoise()
 
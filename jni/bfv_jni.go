@@ -13,15 +13,23 @@ package main
 //	   (*env)->SetByteArrayRegion(env, result, 0, len, input);
 //	   return result;
 // }
+// static void throwIllegalArgument(JNIEnv *env, const char *msg) {
+//     jclass class = (*env)->FindClass(env, "java/lang/IllegalArgumentException");
+//     if (class != NULL) {
+//         (*env)->ThrowNew(env, class, msg);
+//     }
+// }
 import "C"
 import (
 	"fmt"
+	"github.com/fedejinich/hhego/ballot"
 	bfv2 "github.com/fedejinich/hhego/bfv"
 	"github.com/fedejinich/hhego/pasta"
 	"unsafe"
 
 	"github.com/fedejinich/hhego/util"
 	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
@@ -31,6 +39,13 @@ func main() {
 var ParamsLiteral = bfv.PN15QP827pq // todo(fedejinich) should we parametrize this
 var BfvParams, _ = bfv.NewParametersFromLiteral(ParamsLiteral)
 
+var PastaParams = pasta.Params{
+	SecretKeySize:  pasta.SecretKeySize,
+	PlaintextSize:  pasta.PlaintextSize,
+	CiphertextSize: pasta.CiphertextSize,
+	Rounds:         pasta.Rounds,
+}
+
 //export Java_org_rsksmart_BFV_add
 func Java_org_rsksmart_BFV_add(env *C.JNIEnv, obj C.jobject, jOp0 C.jbyteArray, jOp0Len C.jint,
 	jOp1 C.jbyteArray, jOp1Len C.jint) C.jbyteArray {
@@ -67,6 +82,146 @@ func Java_org_rsksmart_BFV_mul(env *C.JNIEnv, obj C.jobject, jOp0 C.jbyteArray,
 	return r
 }
 
+// Java_org_rsksmart_BFV_mulNoRelin multiplies two ciphertexts without
+// relinearizing, the degree 2 result must go through relinearize before
+// rotating or multiplying again. Products can be summed before that, paying
+// a single relinearization.
+//
+//export Java_org_rsksmart_BFV_mulNoRelin
+func Java_org_rsksmart_BFV_mulNoRelin(env *C.JNIEnv, obj C.jobject, jOp0 C.jbyteArray, jOp0Len C.jint,
+	jOp1 C.jbyteArray, jOp1Len C.jint) C.jbyteArray {
+
+	op0 := util.BytesToCiphertext(jBytesToBytes(env, jOp0, jOp0Len), BfvParams)
+	op1 := util.BytesToCiphertext(jBytesToBytes(env, jOp1, jOp1Len), BfvParams)
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).MulNew(op0, op1))
+}
+
+// Java_org_rsksmart_BFV_addPlain adds the plaintext values (one per slot) to
+// the ciphertext
+//
+//export Java_org_rsksmart_BFV_addPlain
+func Java_org_rsksmart_BFV_addPlain(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jValues C.jbyteArray, jValuesLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	pt := encodePlaintext(util.BytesToUint64Array(jBytesToBytes(env, jValues, jValuesLen)), ct.Level())
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).AddNew(ct, pt))
+}
+
+// Java_org_rsksmart_BFV_mulPlain multiplies the ciphertext slot-wise by the
+// plaintext values, e.g. by per slot weights. No relinearization needed.
+//
+//export Java_org_rsksmart_BFV_mulPlain
+func Java_org_rsksmart_BFV_mulPlain(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jValues C.jbyteArray, jValuesLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	pt := encodePlaintext(util.BytesToUint64Array(jBytesToBytes(env, jValues, jValuesLen)), ct.Level())
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).MulNew(ct, pt))
+}
+
+// Java_org_rsksmart_BFV_mulScalar multiplies every slot of the ciphertext by
+// jScalar (mod T)
+//
+//export Java_org_rsksmart_BFV_mulScalar
+func Java_org_rsksmart_BFV_mulScalar(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jScalar C.jlong) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	scalar := uint64(jScalar) % BfvParams.T()
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).MulNew(ct, scalar))
+}
+
+//export Java_org_rsksmart_BFV_negate
+func Java_org_rsksmart_BFV_negate(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint) C.jbyteArray {
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).NegNew(ct))
+}
+
+// Java_org_rsksmart_BFV_rotateColumns rotates both rows of the ciphertext
+// left by jK slots (right if negative), jEvks must hold its galois key
+//
+//export Java_org_rsksmart_BFV_rotateColumns
+func Java_org_rsksmart_BFV_rotateColumns(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint, jK C.jint,
+	jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	return ciphertextToJByteArray(env, evaluator.RotateColumnsNew(ct, int(jK)))
+}
+
+// Java_org_rsksmart_BFV_rotateRows swaps both rows of the ciphertext, jEvks
+// must hold the row rotation galois key
+//
+//export Java_org_rsksmart_BFV_rotateRows
+func Java_org_rsksmart_BFV_rotateRows(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	return ciphertextToJByteArray(env, evaluator.RotateRowsNew(ct))
+}
+
+// Java_org_rsksmart_BFV_relinearize brings a degree 2 ciphertext (see
+// mulNoRelin) back to degree 1
+//
+//export Java_org_rsksmart_BFV_relinearize
+func Java_org_rsksmart_BFV_relinearize(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jRelinearizationKey C.jbyteArray, jRelinearizationKeyLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := evaluatorWithRK(BfvParams, relinearizationKey(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	return ciphertextToJByteArray(env, evaluator.RelinearizeNew(ct))
+}
+
+// Java_org_rsksmart_BFV_rescale divides the ciphertext by its last modulus,
+// dropping one level. It shrinks the ciphertext and keeps its noise budget.
+// It throws an IllegalArgumentException for a ciphertext at level 0.
+//
+//export Java_org_rsksmart_BFV_rescale
+func Java_org_rsksmart_BFV_rescale(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint) C.jbyteArray {
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	if ct.Level() == 0 {
+		throwIllegalArgument(env, fmt.Errorf("the ciphertext is already at level 0"))
+		return 0
+	}
+
+	res := bfv.NewCiphertext(BfvParams, ct.Degree(), ct.Level()-1)
+	if err := evaluatorWithRK(BfvParams, nil).Rescale(ct, res); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+
+	return ciphertextToJByteArray(env, res)
+}
+
+// Java_org_rsksmart_BFV_dropLevel drops jLevels moduli of the ciphertext
+// without rescaling, e.g. to shrink a result that needs no more
+// multiplications before storing it. It throws an IllegalArgumentException if
+// the ciphertext has fewer levels.
+//
+//export Java_org_rsksmart_BFV_dropLevel
+func Java_org_rsksmart_BFV_dropLevel(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jLevels C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	if int(jLevels) < 0 || int(jLevels) > ct.Level() {
+		throwIllegalArgument(env, fmt.Errorf("can't drop %d levels of a ciphertext at level %d", jLevels,
+			ct.Level()))
+		return 0
+	}
+
+	return ciphertextToJByteArray(env, evaluatorWithRK(BfvParams, nil).DropLevelNew(ct, int(jLevels)))
+}
+
 //export Java_org_rsksmart_BFV_decrypt
 func Java_org_rsksmart_BFV_decrypt(env *C.JNIEnv, obj C.jobject, jData C.jbyteArray, jDataLen C.jint,
 	jSK C.jbyteArray, jSKLen C.jint) C.jbyteArray {
@@ -94,6 +249,26 @@ func Java_org_rsksmart_BFV_decrypt(env *C.JNIEnv, obj C.jobject, jData C.jbyteAr
 	return jByteArray
 }
 
+// Java_org_rsksmart_BFV_decryptPacked decrypts the packed ciphertexts
+// transcipher2 and sessionTranscipher return and puts the message back
+// together, as decrypt it returns its elements little endian
+//
+//export Java_org_rsksmart_BFV_decryptPacked
+func Java_org_rsksmart_BFV_decryptPacked(env *C.JNIEnv, obj C.jobject, jPacked C.jbyteArray, jPackedLen C.jint,
+	jSK C.jbyteArray, jSKLen C.jint) C.jbyteArray {
+
+	packed, err := bfv2.UnmarshalPackedCiphertexts(jBytesToBytes(env, jPacked, jPackedLen), BfvParams)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	sk := util.BytesToSecretKey(jBytesToBytes(env, jSK, jSKLen), BfvParams.Parameters)
+
+	message := bfv2.DecryptPacked(packed, bfv.NewDecryptor(BfvParams, sk), bfv.NewEncoder(BfvParams))
+
+	return buildJByteArray(env, util.Uint64ArrayToBytes(message))
+}
+
 //export Java_org_rsksmart_BFV_encrypt
 func Java_org_rsksmart_BFV_encrypt(env *C.JNIEnv, obj C.jobject, jData C.jbyteArray, jDataLen C.jint,
 	jSK C.jbyteArray, jSKLen C.jint) C.jbyteArray {
@@ -122,47 +297,12 @@ func Java_org_rsksmart_BFV_encrypt(env *C.JNIEnv, obj C.jobject, jData C.jbyteAr
 	return r
 }
 
-//export Java_org_rsksmart_BFV_transcipher
-func Java_org_rsksmart_BFV_transcipher(env *C.JNIEnv, obj C.jobject, jEncryptedMessageBytes C.jbyteArray,
-	jEncryptedMessageLen C.jint, jPastaSK C.jbyteArray, jPastaSKLen C.jint, jRelinKey C.jbyteArray,
-	jRelinKeyLen C.jint, jBfvSK C.jbyteArray, jBfvSKLen C.jint) C.jbyteArray {
-
-	// deserialize keys
-	pastaSkBytes := jBytesToBytes(env, jPastaSK, jPastaSKLen)
-	pastaSK := util.BytesToCiphertext(pastaSkBytes, BfvParams)
-
-	bfvSKBytes := jBytesToBytes(env, jBfvSK, jBfvSKLen)
-	bfvSK := util.BytesToSecretKey(bfvSKBytes, BfvParams.Parameters)
-
-	rkBytes := jBytesToBytes(env, jRelinKey, jRelinKeyLen)
-	rk := util.BytesToRelinKey(rkBytes, BfvParams.Parameters)
-
-	// deserialize message
-	messageByteArray := jBytesToBytes(env, jEncryptedMessageBytes, jEncryptedMessageLen)
-
-	message := util.BytesToUint64Array(messageByteArray)
-
-	_, _, evaluator, encoder, _, _ := bfv2.NewBFVPasta(uint64(BfvParams.N()), pasta.DefaultSecLevel,
-		uint64(len(message)), 20, 10, BfvParams.T(), bfvSK, rk)
-
-	// transcipher
-	pastaParams := pasta.Params{
-		SecretKeySize:  pasta.SecretKeySize,
-		PlaintextSize:  pasta.PlaintextSize,
-		CiphertextSize: pasta.CiphertextSize,
-		Rounds:         pasta.Rounds,
-	}
-	res := bfv2.Transcipher(message, pastaSK, pastaParams, pasta.DefaultSecLevel, encoder, evaluator, BfvParams)
-
-	// output
-	resBytes, _ := res.MarshalBinary()
-	var cOutput *C.char = C.CString(string(resBytes)) // todo(fedejinich) will string always work as expected?
-	defer C.free(unsafe.Pointer(cOutput))
-	r := C.fromCByteArray(env, cOutput, C.int(len(resBytes)))
-
-	return r
-}
-
+// Java_org_rsksmart_BFV_transcipher2 transciphers a pasta encrypted message
+// with the bfv encrypted pasta key and the evaluation keys of its owner. A
+// long message spans several ciphertexts, it returns them with the element
+// range each one holds (see bfv.MarshalPackedCiphertexts), decrypt them with
+// decryptPacked.
+//
 //export Java_org_rsksmart_BFV_transcipher2
 func Java_org_rsksmart_BFV_transcipher2(env *C.JNIEnv, obj C.jobject, jEncryptedMessageBytes C.jbyteArray,
 	jEncryptedMessageLen C.jint, jPastaSK C.jbyteArray, jPastaSKLen C.jint, jEvks C.jbyteArray,
@@ -176,34 +316,102 @@ func Java_org_rsksmart_BFV_transcipher2(env *C.JNIEnv, obj C.jobject, jEncrypted
 	//bfvSK := util.BytesToSecretKey(bfvSKBytes, BfvParams.Parameters)
 	//
 	evksBytes := jBytesToBytes(env, jEvks, jEvksLen)
-	evks := util.BytesToEvks(evksBytes)
+	evks, err := cachedEvks(evksBytes)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
 
 	// deserialize message
 	messageByteArray := jBytesToBytes(env, jEncryptedMessageBytes, jEncryptedMessageLen)
 	message := util.BytesToUint64Array(messageByteArray)
 
-	_, _, evaluator, encoder, _, _ := bfv2.NewBFVPastaEvks(uint64(BfvParams.N()), BfvParams.T(), *evks, nil)
+	return transcipherWithEvks(env, message, pastaSK, evks)
+}
+
+// transcipherWithEvks is the transcipher2 circuit, evks must be treated as
+// read only since it may be cached
+func transcipherWithEvks(env *C.JNIEnv, message []uint64, pastaSK *rlwe.Ciphertext,
+	evks *rlwe.EvaluationKeySet) C.jbyteArray {
+
+	evaluator, encoder, _, err := bfv2.NewBFVPastaServer(uint64(BfvParams.N()), BfvParams.T(), evks)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	if err := bfv2.ValidateEvaluationKeys(BfvParams, evks, uint64(len(message)), pasta.DefaultSecLevel,
+		bfv2.TranscipherOptions{Matmul: bfv2.MatmulAuto}); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
 
 	// transcipher
-	pastaParams := pasta.Params{
-		SecretKeySize:  pasta.SecretKeySize,
-		PlaintextSize:  pasta.PlaintextSize,
-		CiphertextSize: pasta.CiphertextSize,
-		Rounds:         pasta.Rounds,
+	res, err := bfv2.TranscipherScratch(message, pastaSK, PastaParams, pasta.DefaultSecLevel, encoder, evaluator,
+		bfv2.NewScratch(BfvParams), bfv2.TranscipherOptions{Matmul: bfv2.MatmulAuto})
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
 	}
 
-	fmt.Println("transciphering message")
-	fmt.Println(message)
+	// output
+	return packedToJByteArray(env, res)
+}
 
-	res := bfv2.Transcipher(message, pastaSK, pastaParams, pasta.DefaultSecLevel, encoder, evaluator, BfvParams)
+// Java_org_rsksmart_BFV_tallyVote transciphers a one-hot vote pasta encrypted
+// with jNonce and adds it to the running tally, an empty jTally starts a new
+// one. Every vote needs its own nonce (see ballot.Tally.AddPasta), the caller
+// keeps track of them. It returns the updated tally ciphertext, decrypt it
+// only once voting is over. A bad candidate count, keys that can't transcipher
+// the vote or a vote of the wrong length throw an IllegalArgumentException.
+//
+//export Java_org_rsksmart_BFV_tallyVote
+func Java_org_rsksmart_BFV_tallyVote(env *C.JNIEnv, obj C.jobject, jTally C.jbyteArray, jTallyLen C.jint,
+	jCandidates C.jint, jVote C.jbyteArray, jVoteLen C.jint, jNonce C.jlong, jPastaSK C.jbyteArray,
+	jPastaSKLen C.jint, jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	// deserialize keys
+	pastaSK := util.BytesToCiphertext(jBytesToBytes(env, jPastaSK, jPastaSKLen), BfvParams)
+	evks := util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen))
+
+	// deserialize tally and vote
+	if jCandidates < 0 {
+		throwIllegalArgument(env, fmt.Errorf("negative candidate count %d", int(jCandidates)))
+		return 0
+	}
+	candidates := uint64(jCandidates)
+	tally, err := ballot.NewTally(BfvParams, candidates)
+	if err == nil && jTallyLen > 0 {
+		tallyCt := bfv.NewCiphertext(BfvParams, 1, BfvParams.MaxLevel())
+		if err = tallyCt.UnmarshalBinary(jBytesToBytes(env, jTally, jTallyLen)); err == nil {
+			tally, err = ballot.ResumeTally(BfvParams, candidates, tallyCt)
+		}
+	}
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	vote := util.BytesToUint64Array(jBytesToBytes(env, jVote, jVoteLen))
+
+	evaluator, encoder, _, err := bfv2.NewBFVPastaServer(uint64(BfvParams.N()), BfvParams.T(), evks)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	if err := bfv2.ValidateEvaluationKeys(BfvParams, evks, candidates, pasta.DefaultSecLevel,
+		bfv2.TranscipherOptions{}); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	if err := tally.AddPasta(vote, uint64(jNonce), pastaSK, encoder, evaluator,
+		bfv2.NewScratch(BfvParams)); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
 
 	// output
-	resBytes, _ := res.MarshalBinary()
-	var cOutput *C.char = C.CString(string(resBytes)) // todo(fedejinich) will string always work as expected?
-	defer C.free(unsafe.Pointer(cOutput))
-	r := C.fromCByteArray(env, cOutput, C.int(len(resBytes)))
+	resBytes, _ := tally.Ciphertext.MarshalBinary()
 
-	return r
+	return buildJByteArray(env, resBytes)
 }
 
 //export Java_org_rsksmart_BFV_noiseBudget
@@ -230,11 +438,196 @@ func Java_org_rsksmart_BFV_noiseBudget(env *C.JNIEnv, obj C.jobject, jCt0 C.jbyt
 	// return noiseBudgetC
 	noiseBudgetInt := int(noiseBudget)
 
-	fmt.Printf("int noise %d\n", noiseBudgetInt)
+	fmt.Fprintf(util.Progress, "int noise %d\n", noiseBudgetInt)
 
 	return C.jint(noiseBudgetInt)
 }
 
+//export Java_org_rsksmart_BFV_sumSlots
+func Java_org_rsksmart_BFV_sumSlots(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint, jN C.jint,
+	jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	res := bfv2.SumSlots(ct, uint64(jN), evaluator, BfvParams)
+
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+//export Java_org_rsksmart_BFV_innerSum
+func Java_org_rsksmart_BFV_innerSum(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	res := bfv2.InnerSum(ct, evaluator, BfvParams)
+
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+//export Java_org_rsksmart_BFV_innerProduct
+func Java_org_rsksmart_BFV_innerProduct(env *C.JNIEnv, obj C.jobject, jCt0 C.jbyteArray, jCt0Len C.jint,
+	jCt1 C.jbyteArray, jCt1Len C.jint, jN C.jint, jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct0 := util.BytesToCiphertext(jBytesToBytes(env, jCt0, jCt0Len), BfvParams)
+	ct1 := util.BytesToCiphertext(jBytesToBytes(env, jCt1, jCt1Len), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	res := bfv2.InnerProduct(ct0, ct1, uint64(jN), evaluator, BfvParams)
+
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+//export Java_org_rsksmart_BFV_replicate
+func Java_org_rsksmart_BFV_replicate(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint, jN C.jint,
+	jEvks C.jbyteArray, jEvksLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := bfv.NewEvaluator(BfvParams, util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen)))
+
+	res := bfv2.Replicate(ct, uint64(jN), evaluator, BfvParams)
+
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+// Java_org_rsksmart_BFV_slotKeys generates, client side, the evaluation keys
+// sumSlots, innerProduct and replicate need on windows of jN slots and, if
+// jInnerSum is set, the ones innerSum needs
+//
+//export Java_org_rsksmart_BFV_slotKeys
+func Java_org_rsksmart_BFV_slotKeys(env *C.JNIEnv, obj C.jobject, jSK C.jbyteArray, jSKLen C.jint, jN C.jint,
+	jInnerSum C.jboolean) C.jbyteArray {
+
+	sk := util.BytesToSecretKey(jBytesToBytes(env, jSK, jSKLen), BfvParams.Parameters)
+
+	n := uint64(jN)
+	galEls := bfv2.SumSlotsGaloisElements(BfvParams.Parameters, n)
+	galEls = append(galEls, bfv2.ReplicateGaloisElements(BfvParams.Parameters, n)...)
+	if jInnerSum != 0 {
+		galEls = append(galEls, bfv2.InnerSumGaloisElements(BfvParams.Parameters)...)
+	}
+	evks := bfv2.GenSlotKeys(BfvParams, sk, galEls)
+
+	evksBytes, _ := evks.MarshalBinary()
+
+	return buildJByteArray(env, evksBytes)
+}
+
+// Java_org_rsksmart_BFV_isZero returns an encryption of 1 on every slot where
+// the ciphertext is zero and 0 elsewhere
+//
+//export Java_org_rsksmart_BFV_isZero
+func Java_org_rsksmart_BFV_isZero(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jRelinearizationKey C.jbyteArray, jRelinearizationKeyLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	evaluator := evaluatorWithRK(BfvParams, relinearizationKey(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	res := bfv2.IsZero(ct, evaluator, BfvParams)
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+// Java_org_rsksmart_BFV_equal returns an encryption of 1 on every slot where
+// both ciphertexts are equal and 0 elsewhere
+//
+//export Java_org_rsksmart_BFV_equal
+func Java_org_rsksmart_BFV_equal(env *C.JNIEnv, obj C.jobject, jOp0 C.jbyteArray, jOp0Len C.jint,
+	jOp1 C.jbyteArray, jOp1Len C.jint, jRelinearizationKey C.jbyteArray, jRelinearizationKeyLen C.jint) C.jbyteArray {
+
+	op0 := util.BytesToCiphertext(jBytesToBytes(env, jOp0, jOp0Len), BfvParams)
+	op1 := util.BytesToCiphertext(jBytesToBytes(env, jOp1, jOp1Len), BfvParams)
+	evaluator := evaluatorWithRK(BfvParams, relinearizationKey(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	res := bfv2.Equal(op0, op1, evaluator, BfvParams)
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+// Java_org_rsksmart_BFV_lessThan returns an encryption of 1 on every slot
+// where the first ciphertext is lower than the second one and 0 elsewhere,
+// both must hold values in [0, jBound)
+//
+//export Java_org_rsksmart_BFV_lessThan
+func Java_org_rsksmart_BFV_lessThan(env *C.JNIEnv, obj C.jobject, jOp0 C.jbyteArray, jOp0Len C.jint,
+	jOp1 C.jbyteArray, jOp1Len C.jint, jBound C.jlong, jRelinearizationKey C.jbyteArray,
+	jRelinearizationKeyLen C.jint) C.jbyteArray {
+
+	op0 := util.BytesToCiphertext(jBytesToBytes(env, jOp0, jOp0Len), BfvParams)
+	op1 := util.BytesToCiphertext(jBytesToBytes(env, jOp1, jOp1Len), BfvParams)
+	evaluator := evaluatorWithRK(BfvParams, relinearizationKey(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	res, err := bfv2.LessThan(op0, op1, uint64(jBound), evaluator, BfvParams)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+// Java_org_rsksmart_BFV_polyEval evaluates the polynomial of coefficients
+// jCoeffs (lowest degree first) on every slot of the ciphertext
+//
+//export Java_org_rsksmart_BFV_polyEval
+func Java_org_rsksmart_BFV_polyEval(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jCoeffs C.jbyteArray, jCoeffsLen C.jint, jRelinearizationKey C.jbyteArray,
+	jRelinearizationKeyLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	coeffs := util.BytesToUint64Array(jBytesToBytes(env, jCoeffs, jCoeffsLen))
+	evaluator := evaluatorWithRK(BfvParams, relinearizationKey(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	res, err := bfv2.EvaluatePoly(ct, coeffs, evaluator, BfvParams)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
+// Java_org_rsksmart_BFV_reencrypt moves a ciphertext to a recipient public
+// key with the re-encryption token the key owner generated for it (see
+// bfv.GenReencryptionToken), the node never sees the secret key. A token only
+// works for the ciphertext it was generated for, a malformed one throws an
+// IllegalArgumentException.
+//
+//export Java_org_rsksmart_BFV_reencrypt
+func Java_org_rsksmart_BFV_reencrypt(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint,
+	jToken C.jbyteArray, jTokenLen C.jint) C.jbyteArray {
+
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+	token := new(drlwe.PCKSShare)
+	if err := token.UnmarshalBinary(jBytesToBytes(env, jToken, jTokenLen)); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	if len(token.Value) != 2 || token.Value[0].N() != BfvParams.N() || token.Value[0].Level() < ct.Level() {
+		throwIllegalArgument(env, fmt.Errorf("the re-encryption token doesn't match the ciphertext parameters"))
+		return 0
+	}
+
+	res := bfv2.Reencrypt(BfvParams, ct, token)
+	resBytes, _ := res.MarshalBinary()
+
+	return buildJByteArray(env, resBytes)
+}
+
 // PrintNoise prints the standard deviation of the noise in the given ciphertext.
 func PrintNoise(evaluator bfv.Evaluator, decryptor rlwe.Decryptor, encoder bfv.Encoder, ct *rlwe.Ciphertext, values []uint64) int {
 	// Encode the coefficients back to a plaintext
@@ -248,7 +641,7 @@ func PrintNoise(evaluator bfv.Evaluator, decryptor rlwe.Decryptor, encoder bfv.E
 	res, _, _ := rlwe.Norm(vec, decryptor)
 
 	// Log the standard deviation of the noise
-	fmt.Printf("STD(noise)res: %d\n", int(res))
+	fmt.Fprintf(util.Progress, "STD(noise)res: %d\n", int(res))
 
 	return int(res)
 }
@@ -272,6 +665,28 @@ func executeOp(env *C.JNIEnv, jOp0 C.jbyteArray, jOp0Len C.jint,
 	return r
 }
 
+// packedToJByteArray returns a transciphered message in the
+// bfv.MarshalPackedCiphertexts format, decryptPacked puts it back together
+func packedToJByteArray(env *C.JNIEnv, res []bfv2.PackedCiphertext) C.jbyteArray {
+	resBytes, _ := bfv2.MarshalPackedCiphertexts(res)
+
+	return buildJByteArray(env, resBytes)
+}
+
+// encodePlaintext encodes one value per slot at level
+func encodePlaintext(values []uint64, level int) *rlwe.Plaintext {
+	pt := bfv.NewPlaintext(BfvParams, level)
+	bfv.NewEncoder(BfvParams).Encode(values, pt)
+
+	return pt
+}
+
+func ciphertextToJByteArray(env *C.JNIEnv, ct *rlwe.Ciphertext) C.jbyteArray {
+	ctBytes, _ := ct.MarshalBinary()
+
+	return buildJByteArray(env, ctBytes)
+}
+
 func buildJByteArray(env *C.JNIEnv, res []byte) C.jbyteArray {
 	var cOutput *C.char = C.CString(string(res))
 	defer C.free(unsafe.Pointer(cOutput))
@@ -280,6 +695,14 @@ func buildJByteArray(env *C.JNIEnv, res []byte) C.jbyteArray {
 	return r
 }
 
+// throwIllegalArgument raises a java IllegalArgumentException with the
+// message of err, the export must return right away, java ignores its result
+func throwIllegalArgument(env *C.JNIEnv, err error) {
+	msg := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(msg))
+	C.throwIllegalArgument(env, msg)
+}
+
 func jBytesToBytes(env *C.JNIEnv, jOp0 C.jbyteArray, jOp0Len C.jint) []byte {
 	cOp0 := C.getCByteArray(env, jOp0)
 	op0 := C.GoBytes(unsafe.Pointer(cOp0), jOp0Len)
@@ -288,6 +711,15 @@ func jBytesToBytes(env *C.JNIEnv, jOp0 C.jbyteArray, jOp0Len C.jint) []byte {
 	return op0
 }
 
+func relinearizationKey(env *C.JNIEnv, jRelinearizationKey C.jbyteArray,
+	jRelinearizationKeyLen C.jint) *rlwe.RelinearizationKey {
+
+	rk := rlwe.NewRelinearizationKey(BfvParams.Parameters)
+	rk.UnmarshalBinary(jBytesToBytes(env, jRelinearizationKey, jRelinearizationKeyLen))
+
+	return rk
+}
+
 func evaluatorWithRK(params bfv.Parameters, rKey *rlwe.RelinearizationKey) bfv.Evaluator {
 	evk := rlwe.NewEvaluationKeySet()
 	if rKey != nil {
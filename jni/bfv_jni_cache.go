@@ -0,0 +1,107 @@
+package main
+
+// #include <jni.h>
+import "C"
+import (
+	"fmt"
+
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// DefaultEvkCacheSize bounds the serialized size of the evaluation key sets
+// kept deserialized across calls, see setEvaluationKeyCacheLimit
+const DefaultEvkCacheSize = 1 << 30
+
+var evkCache = util.NewEvkCache(DefaultEvkCacheSize)
+
+// Java_org_rsksmart_BFV_registerEvaluationKeys caches an evaluation key set
+// and returns its Keccak-256 hash, transcipherCached takes the hash instead
+// of the keys. A key set can be evicted by newer ones (least recently used
+// first), check it with evaluationKeysCached and register it again.
+//
+//export Java_org_rsksmart_BFV_registerEvaluationKeys
+func Java_org_rsksmart_BFV_registerEvaluationKeys(env *C.JNIEnv, obj C.jobject, jEvks C.jbyteArray,
+	jEvksLen C.jint) C.jbyteArray {
+
+	hash, _, err := evkCache.Put(jBytesToBytes(env, jEvks, jEvksLen))
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+
+	return buildJByteArray(env, hash[:])
+}
+
+//export Java_org_rsksmart_BFV_evaluationKeysCached
+func Java_org_rsksmart_BFV_evaluationKeysCached(env *C.JNIEnv, obj C.jobject, jHash C.jbyteArray,
+	jHashLen C.jint) C.jboolean {
+
+	hash, err := evkHash(env, jHash, jHashLen)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	if _, ok := evkCache.Get(hash); ok {
+		return 1
+	}
+
+	return 0
+}
+
+// Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit sets how many bytes of
+// serialized evaluation keys the cache keeps (DefaultEvkCacheSize by
+// default), evicting the least recently used ones if needed. A negative limit
+// throws an IllegalArgumentException.
+//
+//export Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit
+func Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit(env *C.JNIEnv, obj C.jobject, jMaxBytes C.jlong) {
+	if err := evkCache.SetLimit(int64(jMaxBytes)); err != nil {
+		throwIllegalArgument(env, err)
+	}
+}
+
+// Java_org_rsksmart_BFV_transcipherCached is transcipher2 with the keys
+// registered under jHash, it throws an IllegalArgumentException if they
+// aren't cached (anymore)
+//
+//export Java_org_rsksmart_BFV_transcipherCached
+func Java_org_rsksmart_BFV_transcipherCached(env *C.JNIEnv, obj C.jobject, jEncryptedMessageBytes C.jbyteArray,
+	jEncryptedMessageLen C.jint, jPastaSK C.jbyteArray, jPastaSKLen C.jint, jHash C.jbyteArray,
+	jHashLen C.jint) C.jbyteArray {
+
+	hash, err := evkHash(env, jHash, jHashLen)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	evks, ok := evkCache.Get(hash)
+	if !ok {
+		throwIllegalArgument(env, fmt.Errorf("evaluation keys %x aren't cached, register them again", hash))
+		return 0
+	}
+
+	pastaSK := util.BytesToCiphertext(jBytesToBytes(env, jPastaSK, jPastaSKLen), BfvParams)
+	message := util.BytesToUint64Array(jBytesToBytes(env, jEncryptedMessageBytes, jEncryptedMessageLen))
+
+	return transcipherWithEvks(env, message, pastaSK, evks)
+}
+
+// cachedEvks returns the deserialized key set of data through the cache. It
+// fails on malformed keys or a key set larger than the whole cache, raise the
+// limit with setEvaluationKeyCacheLimit then.
+func cachedEvks(data []byte) (*rlwe.EvaluationKeySet, error) {
+	_, evks, err := evkCache.Put(data)
+
+	return evks, err
+}
+
+func evkHash(env *C.JNIEnv, jHash C.jbyteArray, jHashLen C.jint) (util.EvkHash, error) {
+	var hash util.EvkHash
+	if int(jHashLen) != len(hash) {
+		return hash, fmt.Errorf("evaluation key hashes are %d bytes, got %d", len(hash), jHashLen)
+	}
+	copy(hash[:], jBytesToBytes(env, jHash, jHashLen))
+
+	return hash, nil
+}
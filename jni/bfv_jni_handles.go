@@ -0,0 +1,207 @@
+package main
+
+// #include <jni.h>
+import "C"
+import (
+	"fmt"
+	"sync"
+
+	bfv2 "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// handles keeps the objects java loads once (sessions, pasta keys) and
+// refers to by handle in later calls, instead of sending and deserializing
+// them every time
+var handles = util.NewRegistry()
+
+// session holds the evaluation keys of a key owner with the evaluator and
+// encoder built over them. Evaluators and encoders have their own buffers, so
+// each call works on a shallow copy sharing the keys.
+type session struct {
+	evks      *rlwe.EvaluationKeySet
+	evaluator bfv.Evaluator
+	encoder   bfv.Encoder
+	scratches sync.Pool
+}
+
+// call is what one call gets from a session, done gives it back
+type call struct {
+	session   *session
+	evaluator bfv.Evaluator
+	encoder   bfv.Encoder
+	scratch   *bfv2.Scratch
+	done      func()
+}
+
+// Java_org_rsksmart_BFV_newSession loads an evaluation key set (relinearization
+// and galois keys) and returns the handle sessionAdd, sessionSub, sessionMul
+// and sessionTranscipher take, free it with freeHandle. Without a
+// relinearization key it throws an IllegalArgumentException and returns 0,
+// never a handle.
+//
+//export Java_org_rsksmart_BFV_newSession
+func Java_org_rsksmart_BFV_newSession(env *C.JNIEnv, obj C.jobject, jEvks C.jbyteArray, jEvksLen C.jint) C.jlong {
+	// sessionTranscipher checks the galois keys, the other calls may need none
+	evks := util.BytesToEvks(jBytesToBytes(env, jEvks, jEvksLen))
+	if evks.RelinearizationKey == nil {
+		throwIllegalArgument(env, fmt.Errorf("missing relinearization key"))
+		return 0
+	}
+
+	s := &session{evks: evks, evaluator: bfv.NewEvaluator(BfvParams, evks), encoder: bfv.NewEncoder(BfvParams)}
+	s.scratches.New = func() interface{} { return bfv2.NewScratch(BfvParams) }
+
+	return C.jlong(handles.Register(s))
+}
+
+// Java_org_rsksmart_BFV_loadCiphertext loads a ciphertext used across calls,
+// e.g. a bfv encrypted pasta key for sessionTranscipher, and returns its
+// handle, free it with freeHandle
+//
+//export Java_org_rsksmart_BFV_loadCiphertext
+func Java_org_rsksmart_BFV_loadCiphertext(env *C.JNIEnv, obj C.jobject, jCt C.jbyteArray, jCtLen C.jint) C.jlong {
+	ct := util.BytesToCiphertext(jBytesToBytes(env, jCt, jCtLen), BfvParams)
+
+	return C.jlong(handles.Register(ct))
+}
+
+// Java_org_rsksmart_BFV_freeHandle drops a handle returned by newSession or
+// loadCiphertext, calls still using it finish normally. An unknown or already
+// freed handle throws an IllegalArgumentException, as it does in every call
+// taking a handle.
+//
+//export Java_org_rsksmart_BFV_freeHandle
+func Java_org_rsksmart_BFV_freeHandle(env *C.JNIEnv, obj C.jobject, jHandle C.jlong) {
+	if err := handles.Free(int64(jHandle)); err != nil {
+		throwIllegalArgument(env, err)
+	}
+}
+
+//export Java_org_rsksmart_BFV_sessionAdd
+func Java_org_rsksmart_BFV_sessionAdd(env *C.JNIEnv, obj C.jobject, jSession C.jlong, jOp0 C.jbyteArray,
+	jOp0Len C.jint, jOp1 C.jbyteArray, jOp1Len C.jint) C.jbyteArray {
+
+	c, err := acquireSession(jSession)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	defer c.done()
+
+	return executeOp(env, jOp0, jOp0Len, jOp1, jOp1Len, c.evaluator, util.Add, BfvParams)
+}
+
+//export Java_org_rsksmart_BFV_sessionSub
+func Java_org_rsksmart_BFV_sessionSub(env *C.JNIEnv, obj C.jobject, jSession C.jlong, jOp0 C.jbyteArray,
+	jOp0Len C.jint, jOp1 C.jbyteArray, jOp1Len C.jint) C.jbyteArray {
+
+	c, err := acquireSession(jSession)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	defer c.done()
+
+	return executeOp(env, jOp0, jOp0Len, jOp1, jOp1Len, c.evaluator, util.Sub, BfvParams)
+}
+
+//export Java_org_rsksmart_BFV_sessionMul
+func Java_org_rsksmart_BFV_sessionMul(env *C.JNIEnv, obj C.jobject, jSession C.jlong, jOp0 C.jbyteArray,
+	jOp0Len C.jint, jOp1 C.jbyteArray, jOp1Len C.jint) C.jbyteArray {
+
+	c, err := acquireSession(jSession)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	defer c.done()
+
+	return executeOp(env, jOp0, jOp0Len, jOp1, jOp1Len, c.evaluator, util.Mul, BfvParams)
+}
+
+// Java_org_rsksmart_BFV_sessionTranscipher is transcipher2 with the keys of a
+// session and the pasta key loaded with loadCiphertext
+//
+//export Java_org_rsksmart_BFV_sessionTranscipher
+func Java_org_rsksmart_BFV_sessionTranscipher(env *C.JNIEnv, obj C.jobject, jSession C.jlong,
+	jEncryptedMessageBytes C.jbyteArray, jEncryptedMessageLen C.jint, jPastaSK C.jlong) C.jbyteArray {
+
+	c, err := acquireSession(jSession)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	defer c.done()
+	pastaSK, err := acquireCiphertext(jPastaSK)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	defer handles.Release(int64(jPastaSK))
+
+	message := util.BytesToUint64Array(jBytesToBytes(env, jEncryptedMessageBytes, jEncryptedMessageLen))
+
+	opts := bfv2.TranscipherOptions{Matmul: bfv2.MatmulAuto}
+	if err := bfv2.ValidateEvaluationKeys(BfvParams, c.session.evks, uint64(len(message)), pasta.DefaultSecLevel,
+		opts); err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+	res, err := bfv2.TranscipherScratch(message, pastaSK, PastaParams, pasta.DefaultSecLevel, c.encoder,
+		c.evaluator, c.scratch, opts)
+	if err != nil {
+		throwIllegalArgument(env, err)
+		return 0
+	}
+
+	return packedToJByteArray(env, res)
+}
+
+// acquireSession returns shallow copies of the evaluator and encoder of the
+// session of handle, the session is kept alive until done is called. It fails
+// for a stale handle or one that isn't a session.
+func acquireSession(handle C.jlong) (call, error) {
+	v, err := handles.Acquire(int64(handle))
+	if err != nil {
+		return call{}, err
+	}
+	s, ok := v.(*session)
+	if !ok {
+		handles.Release(int64(handle))
+		return call{}, fmt.Errorf("handle %d isn't a session", handle)
+	}
+
+	scratch := s.scratches.Get().(*bfv2.Scratch)
+
+	return call{
+		session:   s,
+		evaluator: s.evaluator.ShallowCopy(),
+		encoder:   s.encoder.ShallowCopy(),
+		scratch:   scratch,
+		done: func() {
+			s.scratches.Put(scratch)
+			handles.Release(int64(handle))
+		},
+	}, nil
+}
+
+// acquireCiphertext returns the ciphertext of handle, release it with
+// handles.Release once done. It must be treated as read only. It fails for a
+// stale handle or one that isn't a ciphertext.
+func acquireCiphertext(handle C.jlong) (*rlwe.Ciphertext, error) {
+	v, err := handles.Acquire(int64(handle))
+	if err != nil {
+		return nil, err
+	}
+	ct, ok := v.(*rlwe.Ciphertext)
+	if !ok {
+		handles.Release(int64(handle))
+		return nil, fmt.Errorf("handle %d isn't a ciphertext", handle)
+	}
+
+	return ct, nil
+}
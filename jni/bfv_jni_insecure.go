@@ -0,0 +1,46 @@
+//go:build insecure
+
+package main
+
+// #include <jni.h>
+import "C"
+import (
+	bfv2 "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+)
+
+// Java_org_rsksmart_BFV_transcipher takes the bfv secret key to generate the
+// galois keys on the node, whoever runs it can decrypt every transciphered
+// message. It's only built with the insecure tag (make GO_TAGS=insecure) and
+// kept for testing, use transcipher2 instead.
+//
+//export Java_org_rsksmart_BFV_transcipher
+func Java_org_rsksmart_BFV_transcipher(env *C.JNIEnv, obj C.jobject, jEncryptedMessageBytes C.jbyteArray,
+	jEncryptedMessageLen C.jint, jPastaSK C.jbyteArray, jPastaSKLen C.jint, jRelinKey C.jbyteArray,
+	jRelinKeyLen C.jint, jBfvSK C.jbyteArray, jBfvSKLen C.jint) C.jbyteArray {
+
+	// deserialize keys
+	pastaSkBytes := jBytesToBytes(env, jPastaSK, jPastaSKLen)
+	pastaSK := util.BytesToCiphertext(pastaSkBytes, BfvParams)
+
+	bfvSKBytes := jBytesToBytes(env, jBfvSK, jBfvSKLen)
+	bfvSK := util.BytesToSecretKey(bfvSKBytes, BfvParams.Parameters)
+
+	rkBytes := jBytesToBytes(env, jRelinKey, jRelinKeyLen)
+	rk := util.BytesToRelinKey(rkBytes, BfvParams.Parameters)
+
+	// deserialize message
+	messageByteArray := jBytesToBytes(env, jEncryptedMessageBytes, jEncryptedMessageLen)
+
+	message := util.BytesToUint64Array(messageByteArray)
+
+	_, _, evaluator, encoder, _, _ := bfv2.NewBFVPasta(uint64(BfvParams.N()), pasta.DefaultSecLevel,
+		uint64(len(message)), BfvParams.T(), bfvSK, rk)
+
+	// transcipher
+	res := bfv2.Transcipher(message, pastaSK, PastaParams, pasta.DefaultSecLevel, encoder, evaluator, BfvParams)
+
+	// output
+	return packedToJByteArray(env, res)
+}
@@ -0,0 +1,43 @@
+//go:build jnitest && insecure
+
+package main
+
+import (
+	"testing"
+
+	bfv2 "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+)
+
+// run with make test GO_TAGS=insecure
+func TestBindingsInsecureTranscipher(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	pastaKey, err := keystore.RandomPastaKey(BfvParams.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pastaKeyCt := bfv2.EncryptPastaSecretKey(pastaKey, bfv.NewEncoder(BfvParams),
+		bfv.NewEncryptor(BfvParams, f.sk), BfvParams)
+	pastaKeyBytes, _ := pastaKeyCt.MarshalBinary()
+	jPastaKey, jPastaKeyLen := f.byteArray(pastaKeyBytes)
+
+	message := []uint64{0, 1, 0, 0}
+	pastaCipher := pasta.NewPasta(pastaKey, BfvParams.T(), PastaParams)
+	jMessage, jMessageLen := f.byteArray(valuesToBytes(pastaCipher.Encrypt(message)))
+
+	res := f.bytes(Java_org_rsksmart_BFV_transcipher(env, obj, jMessage, jMessageLen, jPastaKey, jPastaKeyLen,
+		f.jRk, f.jRkLen, f.jSk, f.jSkLen))
+	packed, err := bfv2.UnmarshalPackedCiphertexts(res, BfvParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted := bfv2.DecryptPacked(packed, bfv.NewDecryptor(BfvParams, f.sk), bfv.NewEncoder(BfvParams))
+	if !util.EqualSlices(decrypted, message) {
+		t.Errorf("transcipher: got %v, expected %v", decrypted, message)
+	}
+}
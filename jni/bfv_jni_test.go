@@ -0,0 +1,490 @@
+//go:build jnitest
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	bfv2 "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// plaintext values go in big endian (util.BytesToUint64Array) and come back
+// from decrypt in little endian (util.Uint64ArrayToBytes)
+func valuesToBytes(values []uint64) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, values)
+
+	return buf.Bytes()
+}
+
+func decryptedValues(data []byte, n int) []uint64 {
+	values := make([]uint64, len(data)/8)
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, values)
+
+	return values[:n]
+}
+
+// bindingCase is the decrypted result of a call, checked against expected on
+// the first len(expected) slots
+type bindingCase struct {
+	name     string
+	result   []byte
+	expected []uint64
+}
+
+// bindingsFixture is a fake JVM holding the keys of a fresh key owner
+type bindingsFixture struct {
+	*fakeJVM
+	sk       *rlwe.SecretKey
+	rk       *rlwe.RelinearizationKey
+	jSk, jRk jByteArray
+	jSkLen   jInt
+	jRkLen   jInt
+}
+
+func newBindingsFixture(t *testing.T) *bindingsFixture {
+	jvm := newFakeJVM()
+	// 2^15 key sets take hundreds of MB, give them back before the next test
+	t.Cleanup(func() {
+		jvm.close()
+		debug.FreeOSMemory()
+	})
+
+	kgen := bfv.NewKeyGenerator(BfvParams)
+	sk := kgen.GenSecretKeyNew()
+	skBytes, _ := sk.MarshalBinary()
+	rk := kgen.GenRelinearizationKeyNew(sk)
+	rkBytes, _ := rk.MarshalBinary()
+
+	f := &bindingsFixture{fakeJVM: jvm, sk: sk, rk: rk}
+	f.jSk, f.jSkLen = jvm.byteArray(skBytes)
+	f.jRk, f.jRkLen = jvm.byteArray(rkBytes)
+
+	return f
+}
+
+// encryptBytes returns an encryption of values
+func (f *bindingsFixture) encryptBytes(values []uint64) []byte {
+	data, dataLen := f.byteArray(valuesToBytes(values))
+
+	return f.bytes(Java_org_rsksmart_BFV_encrypt(f.env, f.obj, data, dataLen, f.jSk, f.jSkLen))
+}
+
+// encrypt returns a java array holding an encryption of values
+func (f *bindingsFixture) encrypt(values []uint64) (jByteArray, jInt) {
+	return f.byteArray(f.encryptBytes(values))
+}
+
+// decrypt returns the first n slots of ct
+func (f *bindingsFixture) decrypt(ct []byte, n int) []uint64 {
+	data, dataLen := f.byteArray(ct)
+	res := Java_org_rsksmart_BFV_decrypt(f.env, f.obj, data, dataLen, f.jSk, f.jSkLen)
+
+	return decryptedValues(f.bytes(res), n)
+}
+
+func (f *bindingsFixture) check(t *testing.T, cases []bindingCase) {
+	for _, c := range cases {
+		if got := f.decrypt(c.result, len(c.expected)); !util.EqualSlices(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.name, got, c.expected)
+		}
+	}
+}
+
+// expectException checks the last call threw an IllegalArgumentException and
+// returned null
+func (f *bindingsFixture) expectException(t *testing.T, name string, res jByteArray) {
+	if exception := f.exception(); !strings.HasPrefix(exception, "java/lang/IllegalArgumentException: ") {
+		t.Errorf("%s: expected an IllegalArgumentException, got %q", name, exception)
+	}
+	if res != 0 {
+		t.Errorf("%s: expected a null result", name)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	ct0, ct0Len := f.encrypt([]uint64{3, 5, 7})
+	ct1, ct1Len := f.encrypt([]uint64{2, 4, 6})
+	weights, weightsLen := f.byteArray(valuesToBytes([]uint64{1, 10, 100}))
+
+	f.check(t, []bindingCase{
+		{"add", f.bytes(Java_org_rsksmart_BFV_add(env, obj, ct0, ct0Len, ct1, ct1Len)), []uint64{5, 9, 13}},
+		{"sub", f.bytes(Java_org_rsksmart_BFV_sub(env, obj, ct0, ct0Len, ct1, ct1Len)), []uint64{1, 1, 1}},
+		{"mul", f.bytes(Java_org_rsksmart_BFV_mul(env, obj, ct0, ct0Len, ct1, ct1Len, f.jRk, f.jRkLen)),
+			[]uint64{6, 20, 42}},
+		{"mulScalar", f.bytes(Java_org_rsksmart_BFV_mulScalar(env, obj, ct0, ct0Len, jlong(3))),
+			[]uint64{9, 15, 21}},
+		{"negate", f.bytes(Java_org_rsksmart_BFV_negate(env, obj, ct0, ct0Len)),
+			[]uint64{BfvParams.T() - 3, BfvParams.T() - 5, BfvParams.T() - 7}},
+		{"mulPlain", f.bytes(Java_org_rsksmart_BFV_mulPlain(env, obj, ct0, ct0Len, weights, weightsLen)),
+			[]uint64{3, 50, 700}},
+		{"addPlain", f.bytes(Java_org_rsksmart_BFV_addPlain(env, obj, ct0, ct0Len, weights, weightsLen)),
+			[]uint64{4, 15, 107}},
+	})
+
+	// products are summed before a single relinearization
+	prod0 := f.bytes(Java_org_rsksmart_BFV_mulNoRelin(env, obj, ct0, ct0Len, ct1, ct1Len))
+	if degree := util.BytesToCiphertext(prod0, BfvParams).Degree(); degree != 2 {
+		t.Errorf("mulNoRelin: expected a degree 2 ciphertext, got %d", degree)
+	}
+	prod1 := f.bytes(Java_org_rsksmart_BFV_mulNoRelin(env, obj, ct0, ct0Len, ct0, ct0Len))
+	jProd0, jProd0Len := f.byteArray(prod0)
+	jProd1, jProd1Len := f.byteArray(prod1)
+	sum := f.bytes(Java_org_rsksmart_BFV_add(env, obj, jProd0, jProd0Len, jProd1, jProd1Len))
+	jSum, jSumLen := f.byteArray(sum)
+	relin := f.bytes(Java_org_rsksmart_BFV_relinearize(env, obj, jSum, jSumLen, f.jRk, f.jRkLen))
+	if degree := util.BytesToCiphertext(relin, BfvParams).Degree(); degree != 1 {
+		t.Errorf("relinearize: expected a degree 1 ciphertext, got %d", degree)
+	}
+	f.check(t, []bindingCase{{"relinearize", relin, []uint64{15, 45, 91}}})
+
+	if budget := Java_org_rsksmart_BFV_noiseBudget(env, obj, ct0, ct0Len, f.jSk, f.jSkLen); budget <= 0 {
+		t.Errorf("noiseBudget: expected a positive budget for a fresh ciphertext, got %d", budget)
+	}
+}
+
+func TestBindingsCompare(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	ct0, ct0Len := f.encrypt([]uint64{3, 5, 7})
+	ct1, ct1Len := f.encrypt([]uint64{4, 5, 1})
+	zeros, zerosLen := f.encrypt([]uint64{0, 5, 0})
+	coeffs, coeffsLen := f.byteArray(valuesToBytes([]uint64{1, 2, 1}))
+
+	f.check(t, []bindingCase{
+		{"isZero", f.bytes(Java_org_rsksmart_BFV_isZero(env, obj, zeros, zerosLen, f.jRk, f.jRkLen)),
+			[]uint64{1, 0, 1}},
+		{"equal", f.bytes(Java_org_rsksmart_BFV_equal(env, obj, ct0, ct0Len, ct1, ct1Len, f.jRk, f.jRkLen)),
+			[]uint64{0, 1, 0}},
+		{"lessThan", f.bytes(Java_org_rsksmart_BFV_lessThan(env, obj, ct0, ct0Len, ct1, ct1Len, jlong(8), f.jRk,
+			f.jRkLen)), []uint64{1, 0, 0}},
+		{"polyEval", f.bytes(Java_org_rsksmart_BFV_polyEval(env, obj, ct0, ct0Len, coeffs, coeffsLen, f.jRk,
+			f.jRkLen)), []uint64{16, 36, 64}},
+	})
+
+	f.expectException(t, "lessThan with a bound above (T+1)/2", Java_org_rsksmart_BFV_lessThan(env, obj, ct0,
+		ct0Len, ct1, ct1Len, jlong(int64(BfvParams.T())), f.jRk, f.jRkLen))
+	f.expectException(t, "lessThan with a negative bound", Java_org_rsksmart_BFV_lessThan(env, obj, ct0, ct0Len,
+		ct1, ct1Len, jlong(-1), f.jRk, f.jRkLen))
+	noCoeffs, noCoeffsLen := f.byteArray([]byte{})
+	f.expectException(t, "polyEval without coefficients", Java_org_rsksmart_BFV_polyEval(env, obj, ct0, ct0Len,
+		noCoeffs, noCoeffsLen, f.jRk, f.jRkLen))
+}
+
+func TestBindingsSlots(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	// the keys of windows of 4 slots
+	jEvks, jEvksLen := f.byteArray(f.bytes(Java_org_rsksmart_BFV_slotKeys(env, obj, f.jSk, f.jSkLen, jint(4), 0)))
+	ct, ctLen := f.encrypt([]uint64{1, 2, 3, 4})
+
+	f.check(t, []bindingCase{
+		{"sumSlots", f.bytes(Java_org_rsksmart_BFV_sumSlots(env, obj, ct, ctLen, jint(4), jEvks, jEvksLen)),
+			[]uint64{10, 9, 7, 4}},
+		{"innerProduct", f.bytes(Java_org_rsksmart_BFV_innerProduct(env, obj, ct, ctLen, ct, ctLen, jint(4), jEvks,
+			jEvksLen)), []uint64{30}},
+		{"replicate", f.bytes(Java_org_rsksmart_BFV_replicate(env, obj, ct, ctLen, jint(4), jEvks, jEvksLen)),
+			[]uint64{1, 2, 3, 4, 1, 2, 3, 4}},
+	})
+}
+
+// innerSum takes a key per power of two up to N/2, its own test keeps the
+// fake JVM from holding them with other keys
+func TestBindingsInnerSum(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	jEvks, jEvksLen := f.byteArray(f.bytes(Java_org_rsksmart_BFV_slotKeys(env, obj, f.jSk, f.jSkLen, jint(1), 1)))
+	debug.FreeOSMemory()
+	ct, ctLen := f.encrypt([]uint64{1, 2, 3, 4})
+
+	f.check(t, []bindingCase{
+		{"innerSum", f.bytes(Java_org_rsksmart_BFV_innerSum(env, obj, ct, ctLen, jEvks, jEvksLen)),
+			[]uint64{10, 10, 10, 10}},
+	})
+}
+
+func TestBindingsReencrypt(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	recipientSk, recipientPk := bfv.NewKeyGenerator(BfvParams).GenKeyPairNew()
+	ctBytes := f.encryptBytes([]uint64{3, 5, 7})
+	ct, ctLen := f.byteArray(ctBytes)
+	token := bfv2.GenReencryptionToken(BfvParams, f.sk, recipientPk, util.BytesToCiphertext(ctBytes, BfvParams))
+	tokenBytes, _ := token.MarshalBinary()
+	jToken, jTokenLen := f.byteArray(tokenBytes)
+
+	res := f.bytes(Java_org_rsksmart_BFV_reencrypt(env, obj, ct, ctLen, jToken, jTokenLen))
+	pt := bfv.NewDecryptor(BfvParams, recipientSk).DecryptNew(util.BytesToCiphertext(res, BfvParams))
+	if got := bfv.NewEncoder(BfvParams).DecodeUintNew(pt)[:3]; !util.EqualSlices(got, []uint64{3, 5, 7}) {
+		t.Errorf("reencrypt: the recipient decrypted %v", got)
+	}
+
+	jMalformed, jMalformedLen := f.byteArray(tokenBytes[:len(tokenBytes)/2])
+	f.expectException(t, "reencrypt with a malformed token", Java_org_rsksmart_BFV_reencrypt(env, obj, ct, ctLen,
+		jMalformed, jMalformedLen))
+}
+
+func TestBindingsSessions(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	evks := rlwe.NewEvaluationKeySet()
+	evks.RelinearizationKey = f.rk
+	evksBytes, _ := evks.MarshalBinary()
+	jEvks, jEvksLen := f.byteArray(evksBytes)
+
+	ct0, ct0Len := f.encrypt([]uint64{3, 5, 7})
+	ct1, ct1Len := f.encrypt([]uint64{2, 4, 6})
+
+	session := Java_org_rsksmart_BFV_newSession(env, obj, jEvks, jEvksLen)
+	f.check(t, []bindingCase{
+		{"sessionAdd", f.bytes(Java_org_rsksmart_BFV_sessionAdd(env, obj, session, ct0, ct0Len, ct1, ct1Len)),
+			[]uint64{5, 9, 13}},
+		{"sessionSub", f.bytes(Java_org_rsksmart_BFV_sessionSub(env, obj, session, ct0, ct0Len, ct1, ct1Len)),
+			[]uint64{1, 1, 1}},
+		{"sessionMul", f.bytes(Java_org_rsksmart_BFV_sessionMul(env, obj, session, ct0, ct0Len, ct1, ct1Len)),
+			[]uint64{6, 20, 42}},
+	})
+
+	// bad handles throw instead of taking the JVM down
+	pastaKey := Java_org_rsksmart_BFV_loadCiphertext(env, obj, ct0, ct0Len)
+	f.expectException(t, "a ciphertext as session", Java_org_rsksmart_BFV_sessionAdd(env, obj, pastaKey, ct0,
+		ct0Len, ct1, ct1Len))
+	f.expectException(t, "a session as pasta key", Java_org_rsksmart_BFV_sessionTranscipher(env, obj, session,
+		ct0, ct0Len, session))
+	Java_org_rsksmart_BFV_freeHandle(env, obj, pastaKey)
+	Java_org_rsksmart_BFV_freeHandle(env, obj, session)
+	if exception := f.exception(); exception != "" {
+		t.Errorf("freeHandle threw %q", exception)
+	}
+	f.expectException(t, "a freed session", Java_org_rsksmart_BFV_sessionAdd(env, obj, session, ct0, ct0Len,
+		ct1, ct1Len))
+	Java_org_rsksmart_BFV_freeHandle(env, obj, session)
+	f.expectException(t, "a double free", 0)
+
+	noRk, _ := rlwe.NewEvaluationKeySet().MarshalBinary()
+	jNoRk, jNoRkLen := f.byteArray(noRk)
+	if handle := Java_org_rsksmart_BFV_newSession(env, obj, jNoRk, jNoRkLen); handle != 0 {
+		t.Errorf("expected no session without a relinearization key, got handle %d", handle)
+	}
+	f.expectException(t, "a session without relinearization key", 0)
+
+	// evaluation key cache
+	hash := f.bytes(Java_org_rsksmart_BFV_registerEvaluationKeys(env, obj, jEvks, jEvksLen))
+	if expected := util.HashEvks(evksBytes); !bytes.Equal(hash, expected[:]) {
+		t.Errorf("registered under %x, expected %x", hash, expected)
+	}
+	jHash, jHashLen := f.byteArray(hash)
+	if Java_org_rsksmart_BFV_evaluationKeysCached(env, obj, jHash, jHashLen) == 0 {
+		t.Errorf("the registered keys aren't cached")
+	}
+	Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit(env, obj, jlong(0))
+	if Java_org_rsksmart_BFV_evaluationKeysCached(env, obj, jHash, jHashLen) != 0 {
+		t.Errorf("the keys weren't evicted")
+	}
+	Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit(env, obj, jlong(-1))
+	f.expectException(t, "a negative cache limit", 0)
+	Java_org_rsksmart_BFV_setEvaluationKeyCacheLimit(env, obj, jlong(DefaultEvkCacheSize))
+
+	jShortHash, jShortHashLen := f.byteArray(hash[1:])
+	if Java_org_rsksmart_BFV_evaluationKeysCached(env, obj, jShortHash, jShortHashLen) != 0 {
+		t.Errorf("a truncated hash is cached")
+	}
+	f.expectException(t, "a truncated hash", 0)
+	jMalformed, jMalformedLen := f.byteArray(evksBytes[:len(evksBytes)/2])
+	f.expectException(t, "registering malformed keys", Java_org_rsksmart_BFV_registerEvaluationKeys(env, obj,
+		jMalformed, jMalformedLen))
+}
+
+func TestBindingsRotations(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	kgen := bfv.NewKeyGenerator(BfvParams)
+	evks := rlwe.NewEvaluationKeySet()
+	for _, galEl := range []uint64{BfvParams.GaloisElementForColumnRotationBy(1),
+		BfvParams.GaloisElementForRowRotation()} {
+		evks.GaloisKeys[galEl] = kgen.GenGaloisKeyNew(galEl, f.sk)
+	}
+	evksBytes, _ := evks.MarshalBinary()
+	jEvks, jEvksLen := f.byteArray(evksBytes)
+
+	ct, ctLen := f.encrypt([]uint64{3, 5, 7})
+	swapped := f.bytes(Java_org_rsksmart_BFV_rotateRows(env, obj, ct, ctLen, jEvks, jEvksLen))
+	jSwapped, jSwappedLen := f.byteArray(swapped)
+	halfslots := BfvParams.N() / 2
+
+	f.check(t, []bindingCase{
+		{"rotateColumns", f.bytes(Java_org_rsksmart_BFV_rotateColumns(env, obj, ct, ctLen, jint(1), jEvks,
+			jEvksLen)), []uint64{5, 7, 0}},
+		{"rotateRows", swapped, []uint64{0, 0, 0}},
+		{"rotateRows twice", f.bytes(Java_org_rsksmart_BFV_rotateRows(env, obj, jSwapped, jSwappedLen, jEvks,
+			jEvksLen)), []uint64{3, 5, 7}},
+	})
+	if got := f.decrypt(swapped, halfslots+3)[halfslots:]; !util.EqualSlices(got, []uint64{3, 5, 7}) {
+		t.Errorf("rotateRows: expected the first row on the second one, got %v", got)
+	}
+}
+
+func TestBindingsLevels(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	ct, ctLen := f.encrypt([]uint64{3, 5, 7})
+	level := func(ct []byte) int {
+		return util.BytesToCiphertext(ct, BfvParams).Level()
+	}
+
+	rescaled := f.bytes(Java_org_rsksmart_BFV_rescale(env, obj, ct, ctLen))
+	if got := level(rescaled); got != BfvParams.MaxLevel()-1 {
+		t.Errorf("rescale: expected level %d, got %d", BfvParams.MaxLevel()-1, got)
+	}
+	dropped := f.bytes(Java_org_rsksmart_BFV_dropLevel(env, obj, ct, ctLen, jint(BfvParams.MaxLevel())))
+	if got := level(dropped); got != 0 {
+		t.Errorf("dropLevel: expected level 0, got %d", got)
+	}
+	f.check(t, []bindingCase{
+		{"rescale", rescaled, []uint64{3, 5, 7}},
+		{"dropLevel", dropped, []uint64{3, 5, 7}},
+	})
+
+	// bad input throws instead of taking the JVM down
+	jDropped, jDroppedLen := f.byteArray(dropped)
+	f.expectException(t, "rescale at level 0", Java_org_rsksmart_BFV_rescale(env, obj, jDropped, jDroppedLen))
+	f.expectException(t, "dropLevel below 0", Java_org_rsksmart_BFV_dropLevel(env, obj, ct, ctLen,
+		jint(BfvParams.MaxLevel()+1)))
+	f.expectException(t, "dropLevel of negative levels", Java_org_rsksmart_BFV_dropLevel(env, obj, ct, ctLen,
+		jint(-1)))
+}
+
+func TestBindingsTranscipher(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	message := []uint64{0, 1, 0, 0}
+	evks := bfv2.GenTranscipherKeys(BfvParams, f.sk, uint64(len(message)),
+		bfv2.TranscipherOptions{Matmul: bfv2.MatmulAuto})
+	evksBytes, _ := evks.MarshalBinary()
+	jEvks, jEvksLen := f.byteArray(evksBytes)
+
+	pastaKey, err := keystore.RandomPastaKey(BfvParams.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pastaKeyCt := bfv2.EncryptPastaSecretKey(pastaKey, bfv.NewEncoder(BfvParams),
+		bfv.NewEncryptor(BfvParams, f.sk), BfvParams)
+	pastaKeyBytes, _ := pastaKeyCt.MarshalBinary()
+	jPastaKey, jPastaKeyLen := f.byteArray(pastaKeyBytes)
+	pastaCipher := pasta.NewPasta(pastaKey, BfvParams.T(), PastaParams)
+	jMessage, jMessageLen := f.byteArray(valuesToBytes(pastaCipher.Encrypt(message)))
+
+	decryptPacked := func(packed []byte) []uint64 {
+		jPacked, jPackedLen := f.byteArray(packed)
+		res := Java_org_rsksmart_BFV_decryptPacked(env, obj, jPacked, jPackedLen, f.jSk, f.jSkLen)
+		return decryptedValues(f.bytes(res), len(message))
+	}
+
+	res := f.bytes(Java_org_rsksmart_BFV_transcipher2(env, obj, jMessage, jMessageLen, jPastaKey, jPastaKeyLen,
+		jEvks, jEvksLen))
+	if got := decryptPacked(res); !util.EqualSlices(got, message) {
+		t.Errorf("transcipher2: got %v, expected %v", got, message)
+	}
+
+	// missing galois keys are reported up front
+	rkOnly := rlwe.NewEvaluationKeySet()
+	rkOnly.RelinearizationKey = f.rk
+	rkOnlyBytes, _ := rkOnly.MarshalBinary()
+	jRkOnly, jRkOnlyLen := f.byteArray(rkOnlyBytes)
+	f.expectException(t, "transcipher2 without galois keys", Java_org_rsksmart_BFV_transcipher2(env, obj,
+		jMessage, jMessageLen, jPastaKey, jPastaKeyLen, jRkOnly, jRkOnlyLen))
+
+	session := Java_org_rsksmart_BFV_newSession(env, obj, jEvks, jEvksLen)
+	defer Java_org_rsksmart_BFV_freeHandle(env, obj, session)
+	pastaKeyHandle := Java_org_rsksmart_BFV_loadCiphertext(env, obj, jPastaKey, jPastaKeyLen)
+	defer Java_org_rsksmart_BFV_freeHandle(env, obj, pastaKeyHandle)
+	res = f.bytes(Java_org_rsksmart_BFV_sessionTranscipher(env, obj, session, jMessage, jMessageLen,
+		pastaKeyHandle))
+	if got := decryptPacked(res); !util.EqualSlices(got, message) {
+		t.Errorf("sessionTranscipher: got %v, expected %v", got, message)
+	}
+
+	// the same keys through the cache
+	hash := f.bytes(Java_org_rsksmart_BFV_registerEvaluationKeys(env, obj, jEvks, jEvksLen))
+	jHash, jHashLen := f.byteArray(hash)
+	res = f.bytes(Java_org_rsksmart_BFV_transcipherCached(env, obj, jMessage, jMessageLen, jPastaKey,
+		jPastaKeyLen, jHash, jHashLen))
+	if got := decryptPacked(res); !util.EqualSlices(got, message) {
+		t.Errorf("transcipherCached: got %v, expected %v", got, message)
+	}
+	jUnknown, jUnknownLen := f.byteArray(make([]byte, len(hash)))
+	f.expectException(t, "transcipherCached with unknown keys", Java_org_rsksmart_BFV_transcipherCached(env, obj,
+		jMessage, jMessageLen, jPastaKey, jPastaKeyLen, jUnknown, jUnknownLen))
+
+	jMalformed, jMalformedLen := f.byteArray(res[:len(res)-1])
+	f.expectException(t, "decryptPacked of truncated ciphertexts", Java_org_rsksmart_BFV_decryptPacked(env, obj,
+		jMalformed, jMalformedLen, f.jSk, f.jSkLen))
+}
+
+func TestBindingsTallyVote(t *testing.T) {
+	f := newBindingsFixture(t)
+	env, obj := f.env, f.obj
+
+	candidates := 4
+	evks := bfv2.GenTranscipherKeys(BfvParams, f.sk, uint64(candidates), bfv2.TranscipherOptions{})
+	evksBytes, _ := evks.MarshalBinary()
+	jEvks, jEvksLen := f.byteArray(evksBytes)
+
+	pastaKey, err := keystore.RandomPastaKey(BfvParams.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pastaKeyCt := bfv2.EncryptPastaSecretKey(pastaKey, bfv.NewEncoder(BfvParams),
+		bfv.NewEncryptor(BfvParams, f.sk), BfvParams)
+	pastaKeyBytes, _ := pastaKeyCt.MarshalBinary()
+	jPastaKey, jPastaKeyLen := f.byteArray(pastaKeyBytes)
+	pastaCipher := pasta.NewPasta(pastaKey, BfvParams.T(), PastaParams)
+
+	// an empty tally starts a new one, every vote comes with its own nonce
+	tally := []byte{}
+	for v, vote := range [][]uint64{{0, 1, 0, 0}, {1, 0, 0, 0}} {
+		nonce := int64(1000 + v)
+		jTally, jTallyLen := f.byteArray(tally)
+		jVote, jVoteLen := f.byteArray(valuesToBytes(pastaCipher.EncryptWithNonce(vote, uint64(nonce))))
+		tally = f.bytes(Java_org_rsksmart_BFV_tallyVote(env, obj, jTally, jTallyLen, jint(candidates), jVote,
+			jVoteLen, jlong(nonce), jPastaKey, jPastaKeyLen, jEvks, jEvksLen))
+	}
+	f.check(t, []bindingCase{{"tallyVote", tally, []uint64{1, 1, 0, 0}}})
+
+	jTally, jTallyLen := f.byteArray(tally)
+	jVote, jVoteLen := f.byteArray(valuesToBytes(pastaCipher.EncryptWithNonce([]uint64{0, 0, 1, 0}, 1002)))
+	f.expectException(t, "tallyVote without candidates", Java_org_rsksmart_BFV_tallyVote(env, obj, jTally,
+		jTallyLen, jint(0), jVote, jVoteLen, jlong(1002), jPastaKey, jPastaKeyLen, jEvks, jEvksLen))
+	f.expectException(t, "tallyVote with a negative candidate count", Java_org_rsksmart_BFV_tallyVote(env, obj,
+		jTally, jTallyLen, jint(-1), jVote, jVoteLen, jlong(1002), jPastaKey, jPastaKeyLen, jEvks, jEvksLen))
+	f.expectException(t, "tallyVote with too many candidates", Java_org_rsksmart_BFV_tallyVote(env, obj, jTally,
+		jTallyLen, jint(BfvParams.N()), jVote, jVoteLen, jlong(1002), jPastaKey, jPastaKeyLen, jEvks, jEvksLen))
+	jShortVote, jShortVoteLen := f.byteArray(valuesToBytes([]uint64{1, 2}))
+	f.expectException(t, "tallyVote of the wrong length", Java_org_rsksmart_BFV_tallyVote(env, obj, jTally,
+		jTallyLen, jint(candidates), jShortVote, jShortVoteLen, jlong(1002), jPastaKey, jPastaKeyLen, jEvks,
+		jEvksLen))
+	noEvks, _ := rlwe.NewEvaluationKeySet().MarshalBinary()
+	jNoEvks, jNoEvksLen := f.byteArray(noEvks)
+	f.expectException(t, "tallyVote without evaluation keys", Java_org_rsksmart_BFV_tallyVote(env, obj, jTally,
+		jTallyLen, jint(candidates), jVote, jVoteLen, jlong(1002), jPastaKey, jPastaKeyLen, jNoEvks, jNoEvksLen))
+}
@@ -0,0 +1,7 @@
+project_root="${PROJECT_ROOT:?set PROJECT_ROOT to the java project}"
+
+make linux &&\
+  mkdir -p "${project_root}/src/main/resources/org/rsksmart/linux-amd64" \
+    "${project_root}/src/main/resources/org/rsksmart/linux-arm64" &&\
+  mv linux-amd64/libbfv_jni.so "${project_root}/src/main/resources/org/rsksmart/linux-amd64/libbfv_jni.so" &&\
+  mv linux-arm64/libbfv_jni.so "${project_root}/src/main/resources/org/rsksmart/linux-arm64/libbfv_jni.so"
@@ -1,4 +1,4 @@
-project_root="/Users/fedejinich/Projects/bfvjava"
+project_root="${PROJECT_ROOT:-/Users/fedejinich/Projects/bfvjava}"
 
 make macos &&\
   mv libbfv_jni.dylib "${project_root}/src/main/resources/org/rsksmart/macos/libbfv_jni.dylib"
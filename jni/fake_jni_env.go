@@ -0,0 +1,146 @@
+//go:build jnitest
+
+package main
+
+// #include <jni.h>
+// #include <stdio.h>
+// #include <stdlib.h>
+// #include <string.h>
+//
+// typedef struct {
+//     jsize len;
+//     jbyte *data;
+// } fake_array;
+//
+// static jbyte* fake_get(JNIEnv *env, jbyteArray array, jboolean *isCopy) {
+//     return ((fake_array*)array)->data;
+// }
+// static void fake_release(JNIEnv *env, jbyteArray array, jbyte *elems, jint mode) {
+// }
+// static jbyteArray fake_new(JNIEnv *env, jsize len) {
+//     fake_array *array = malloc(sizeof(fake_array));
+//     array->len = len;
+//     array->data = calloc(len > 0 ? len : 1, 1);
+//     return array;
+// }
+// static void fake_set(JNIEnv *env, jbyteArray array, jsize start, jsize len, const jbyte *buf) {
+//     memcpy(((fake_array*)array)->data + start, buf, len);
+// }
+//
+// // classes are their names, the last exception thrown is kept until the
+// // test takes it
+// static char *fake_exception;
+// static jclass fake_find_class(JNIEnv *env, const char *name) {
+//     return (jclass)name;
+// }
+// static jint fake_throw_new(JNIEnv *env, jclass class, const char *msg) {
+//     size_t len = strlen(class) + strlen(msg) + 3;
+//     free(fake_exception);
+//     fake_exception = malloc(len);
+//     snprintf(fake_exception, len, "%s: %s", (const char*)class, msg);
+//     return 0;
+// }
+// static char* fake_take_exception() {
+//     char *exception = fake_exception;
+//     fake_exception = NULL;
+//     return exception;
+// }
+//
+// static struct JNINativeInterface_ fake_functions;
+// static JNIEnv fake_env_value;
+// static JNIEnv* fake_env() {
+//     fake_functions.GetByteArrayElements = fake_get;
+//     fake_functions.ReleaseByteArrayElements = fake_release;
+//     fake_functions.NewByteArray = fake_new;
+//     fake_functions.SetByteArrayRegion = fake_set;
+//     fake_functions.FindClass = fake_find_class;
+//     fake_functions.ThrowNew = fake_throw_new;
+//     fake_env_value = &fake_functions;
+//     return &fake_env_value;
+// }
+//
+// static jbyteArray fake_array_from(const void *data, jsize len) {
+//     jbyteArray array = fake_new(NULL, len);
+//     memcpy(((fake_array*)array)->data, data, len);
+//     return array;
+// }
+// static jsize fake_array_len(jbyteArray array) {
+//     return ((fake_array*)array)->len;
+// }
+// static jbyte* fake_array_data(jbyteArray array) {
+//     return ((fake_array*)array)->data;
+// }
+// static void fake_array_free(jbyteArray array) {
+//     free(((fake_array*)array)->data);
+//     free(array);
+// }
+import "C"
+import "unsafe"
+
+// fakeJVM stands in for the JVM when testing the bindings: its JNIEnv only
+// implements the byte array and exception functions they call, arrays live
+// in C memory as they would in the JVM heap. Only built with the jnitest tag
+// (see make test).
+type fakeJVM struct {
+	env    *C.JNIEnv
+	obj    C.jobject // the java object the methods are called on, unused
+	arrays []C.jbyteArray
+}
+
+func newFakeJVM() *fakeJVM {
+	return &fakeJVM{env: C.fake_env()}
+}
+
+// byteArray copies data into a java byte array, it returns the array and its
+// length as the bindings take them
+func (j *fakeJVM) byteArray(data []byte) (C.jbyteArray, C.jint) {
+	var ptr unsafe.Pointer
+	if len(data) > 0 {
+		ptr = unsafe.Pointer(&data[0])
+	}
+	array := C.fake_array_from(ptr, C.jsize(len(data)))
+	j.arrays = append(j.arrays, array)
+
+	return array, C.jint(len(data))
+}
+
+// bytes copies a byte array returned by the bindings and frees it
+func (j *fakeJVM) bytes(array C.jbyteArray) []byte {
+	defer C.fake_array_free(array)
+
+	return C.GoBytes(unsafe.Pointer(C.fake_array_data(array)), C.int(C.fake_array_len(array)))
+}
+
+// exception returns the exception the last call threw as "class: message",
+// or "" if it threw none, and clears it
+func (j *fakeJVM) exception() string {
+	exception := C.fake_take_exception()
+	if exception == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(exception))
+
+	return C.GoString(exception)
+}
+
+// close frees the arrays created with byteArray
+func (j *fakeJVM) close() {
+	for _, array := range j.arrays {
+		C.fake_array_free(array)
+	}
+	j.arrays = nil
+}
+
+// jByteArray and jInt name the java types for the tests, which can't use cgo
+type (
+	jByteArray = C.jbyteArray
+	jInt       = C.jint
+)
+
+func jint(n int) C.jint {
+	return C.jint(n)
+}
+
+func jlong(n int64) C.jlong {
+	return C.jlong(n)
+}
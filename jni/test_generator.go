@@ -1,3 +1,5 @@
+//go:build ignore
+
 package main
 
 import (
@@ -155,7 +157,7 @@ func generateTranscipherCase() {
 	rlkBytes, _ := rlk.MarshalBinary()
 
 	// new bfv cipher
-	encryptor, _, _, encoder, bfv, _ := bfv2.NewBFVPasta(uint64(bfvParams.N()), pasta.DefaultSecLevel, encryptedMessageLen, 20, 10, mod, bfvSk, rlk)
+	encryptor, _, _, encoder, bfv, _ := bfv2.NewBFVPasta(uint64(bfvParams.N()), pasta.DefaultSecLevel, encryptedMessageLen, mod, bfvSk, rlk)
 
 	// BFV encrypt PASTA secret key
 	pastaSKCt := bfv2.EncryptPastaSecretKey(pastaSK, encoder, encryptor, bfv.Params)
@@ -307,7 +309,7 @@ func generateSimpleHHE() {
 
 	// new bfv cipher
 	encryptor, _, _, encoder, bfv, evks := bfv2.NewBFVPasta(uint64(bfvParams.N()),
-		pasta.DefaultSecLevel, uint64(len(op1)), 20, 10, mod, bfvSK, rlk)
+		pasta.DefaultSecLevel, uint64(len(op1)), mod, bfvSK, rlk)
 
 	// BFV encrypt PASTA secret key
 	pastaSKCt := bfv2.EncryptPastaSecretKey(pastaSK, encoder, encryptor, bfv.Params)
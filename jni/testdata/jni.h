@@ -0,0 +1,33 @@
+/*
+ * Minimal stand-in for the JDK's jni.h, declaring only what the bindings use,
+ * so they can be built and tested against the fake JNIEnv of fake_jni_env.go
+ * without a JDK (see make test). Releases are built against the real one.
+ */
+#ifndef _JAVASOFT_JNI_H_
+#define _JAVASOFT_JNI_H_
+
+#include <stdint.h>
+
+typedef int32_t jint;
+typedef int64_t jlong;
+typedef int8_t jbyte;
+typedef uint8_t jboolean;
+typedef jint jsize;
+
+typedef void *jobject;
+typedef jobject jclass;
+typedef jobject jbyteArray;
+
+struct JNINativeInterface_;
+typedef const struct JNINativeInterface_ *JNIEnv;
+
+struct JNINativeInterface_ {
+    jbyte *(*GetByteArrayElements)(JNIEnv *env, jbyteArray array, jboolean *isCopy);
+    void (*ReleaseByteArrayElements)(JNIEnv *env, jbyteArray array, jbyte *elems, jint mode);
+    jbyteArray (*NewByteArray)(JNIEnv *env, jsize len);
+    void (*SetByteArrayRegion)(JNIEnv *env, jbyteArray array, jsize start, jsize len, const jbyte *buf);
+    jclass (*FindClass)(JNIEnv *env, const char *name);
+    jint (*ThrowNew)(JNIEnv *env, jclass class, const char *msg);
+};
+
+#endif
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	bfv2 "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/keystore"
 	"github.com/fedejinich/hhego/pasta"
 	"github.com/tuneinsight/lattigo/v4/bfv"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
@@ -17,12 +18,17 @@ import (
 const VOTE_COUNT = 50 
 const HARDCODED_VOTES = false 
 
+// INCLUDE_BFV_SK embeds the bfv secret key in votes.json next to the public
+// material, only meant for test fixtures. The key is always written to
+// client.bundle.
+const INCLUDE_BFV_SK = false
+
 type VotesJSON struct {
 	Votes      [][]uint64 `json:"votes"`
 	VotesPasta [][]uint64 `json:"votesPasta"`
 	PastaSK    []byte     `json:"pastaSK"`
 	Rk         []byte     `json:"rk"`
-	BfvSK      []byte     `json:"bfvSK"`
+	BfvSK      []byte     `json:"bfvSK,omitempty"`
 }
 
 func main() {
@@ -80,7 +86,7 @@ func main() {
 	// new bfv cipher
 	voteLen := uint64(4)
 	encryptor, _, _, encoder, _, _ := bfv2.NewBFVPasta(uint64(bfvParams.N()),
-		pasta.DefaultSecLevel, voteLen, 20, 10, mod, bfvSK, rlk)
+		pasta.DefaultSecLevel, voteLen, mod, bfvSK, rlk)
 
 	votes := make([][]uint64, VOTE_COUNT)
 	votesPasta := make([][]uint64, VOTE_COUNT)
@@ -106,8 +112,17 @@ func main() {
 	// relin key bytes
 	rlkBytes, _ := rlk.MarshalBinary()
 
-	// bfvSK bytes
-	bfvSKBytes, _ := bfvSK.MarshalBinary()
+	// bfvSK goes to its own owner-only bundle
+	client := &keystore.ClientBundle{Degree: uint64(bfvParams.N()), Modulus: mod, SecretKey: bfvSK,
+		PastaKey: pastaSK}
+	if err := keystore.WriteClientBundle("client.bundle", client, nil); err != nil {
+		panic("couldn't write client bundle")
+	}
+
+	var bfvSKBytes []byte
+	if INCLUDE_BFV_SK {
+		bfvSKBytes, _ = bfvSK.MarshalBinary()
+	}
 
 	votesJson := VotesJSON{votes, votesPasta, pastaSKCtBytes, rlkBytes, bfvSKBytes}
 
@@ -0,0 +1,264 @@
+// Package keystore stores hhe keys on disk split in two bundles. The client
+// bundle holds the secrets (bfv secret key and pasta key) and never leaves
+// the data owner, the server bundle holds everything needed to transcipher
+// and evaluate (public key, relinearization and Galois keys, bfv encrypted
+// pasta key).
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"runtime"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/pasta"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+const (
+	clientPerm = os.FileMode(0600)
+	serverPerm = os.FileMode(0644)
+)
+
+// ClientBundle is the secret half of a key set
+type ClientBundle struct {
+	Degree    uint64
+	Modulus   uint64
+	SecretKey *rlwe.SecretKey
+	PastaKey  []uint64
+}
+
+// ServerBundle is the public half of a key set, it's enough to transcipher
+// and evaluate but not to decrypt
+type ServerBundle struct {
+	Degree         uint64
+	Modulus        uint64
+	PublicKey      *rlwe.PublicKey
+	EvaluationKeys *rlwe.EvaluationKeySet
+	PastaKeyCt     *rlwe.Ciphertext
+}
+
+// Params returns the bfv parameters the bundle was generated with
+func (c *ClientBundle) Params() bfv.Parameters {
+	return hhegobfv.GenerateBfvParams(c.Modulus, c.Degree)
+}
+
+// Params returns the bfv parameters the bundle was generated with
+func (s *ServerBundle) Params() bfv.Parameters {
+	return hhegobfv.GenerateBfvParams(s.Modulus, s.Degree)
+}
+
+// Generate creates a fresh key set able to transcipher messages of up to
+// messageLength elements
+func Generate(degree, modulus, messageLength uint64) (*ClientBundle, *ServerBundle, error) {
+	params := hhegobfv.GenerateBfvParams(modulus, degree)
+	keygen := rlwe.NewKeyGenerator(params.Parameters)
+	sk, pk := keygen.GenKeyPairNew()
+	evk := hhegobfv.GenTranscipherKeys(params, sk, messageLength, hhegobfv.TranscipherOptions{})
+
+	pastaKey, err := RandomPastaKey(modulus)
+	if err != nil {
+		return nil, nil, err
+	}
+	pastaKeyCt := hhegobfv.EncryptPastaSecretKey(pastaKey, bfv.NewEncoder(params), bfv.NewEncryptor(params, pk),
+		params)
+
+	client := &ClientBundle{degree, modulus, sk, pastaKey}
+	server := &ServerBundle{degree, modulus, pk, &evk, pastaKeyCt}
+
+	return client, server, nil
+}
+
+// RandomPastaKey samples a uniform pasta secret key mod modulus
+func RandomPastaKey(modulus uint64) ([]uint64, error) {
+	key := make([]uint64, pasta.SecretKeySize)
+	max := new(big.Int).SetUint64(modulus)
+	for i := range key {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, err
+		}
+		key[i] = v.Uint64()
+	}
+
+	return key, nil
+}
+
+// WriteClientBundle writes c to path, readable by its owner only. When
+// passphrase isn't empty the bundle is sealed with scrypt and AES-GCM.
+func WriteClientBundle(path string, c *ClientBundle, passphrase []byte) error {
+	skBytes, err := c.SecretKey.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// big endian, like the pasta key files of the cli
+	pastaKey := make([]byte, 8*len(c.PastaKey))
+	for i, v := range c.PastaKey {
+		binary.BigEndian.PutUint64(pastaKey[8*i:], v)
+	}
+	payload := joinSections(skBytes, pastaKey)
+
+	kind := util.KindClientBundle
+	if len(passphrase) > 0 {
+		kind = util.KindClientBundleSealed
+		if payload, err = seal(payload, passphrase, additionalData(kind, c.Degree, c.Modulus)); err != nil {
+			return err
+		}
+	}
+
+	return util.WriteFile(path, util.NewFile(kind, c.Degree, c.Modulus, payload), clientPerm)
+}
+
+// ReadClientBundle reads a client bundle, passphrase is only used if the
+// bundle was sealed. Bundles readable by group or others are rejected.
+func ReadClientBundle(path string, passphrase []byte) (*ClientBundle, error) {
+	if err := checkPerm(path, 0077); err != nil {
+		return nil, err
+	}
+
+	f, err := util.ReadFile(path, "")
+	if err != nil {
+		return nil, err
+	}
+
+	payload := f.Payload
+	switch f.Kind {
+	case util.KindClientBundle:
+	case util.KindClientBundleSealed:
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("%s: bundle is passphrase protected", path)
+		}
+		if payload, err = open(payload, passphrase, additionalData(f.Kind, f.Degree, f.Modulus)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected a client bundle, got %s", path, f.Kind)
+	}
+
+	sections, err := splitSections(payload, 2)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	c := &ClientBundle{Degree: f.Degree, Modulus: f.Modulus}
+	c.SecretKey = rlwe.NewSecretKey(c.Params().Parameters)
+	if err := c.SecretKey.UnmarshalBinary(sections[0]); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(sections[1]) != 8*pasta.SecretKeySize {
+		return nil, fmt.Errorf("%s: malformed pasta key", path)
+	}
+	c.PastaKey = util.BytesToUint64Array(sections[1])
+
+	return c, nil
+}
+
+// WriteServerBundle writes s to path
+func WriteServerBundle(path string, s *ServerBundle) error {
+	var sections [][]byte
+	for _, obj := range []interface{ MarshalBinary() ([]byte, error) }{s.PublicKey, s.EvaluationKeys,
+		s.PastaKeyCt} {
+		data, err := obj.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		sections = append(sections, data)
+	}
+
+	f := util.NewFile(util.KindServerBundle, s.Degree, s.Modulus, joinSections(sections...))
+
+	return util.WriteFile(path, f, serverPerm)
+}
+
+// ReadServerBundle reads a server bundle. Bundles writable by group or others
+// are rejected, since tampered evaluation keys would go unnoticed.
+func ReadServerBundle(path string) (*ServerBundle, error) {
+	if err := checkPerm(path, 0022); err != nil {
+		return nil, err
+	}
+
+	f, err := util.ReadFile(path, util.KindServerBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := splitSections(f.Payload, 3)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	s := &ServerBundle{Degree: f.Degree, Modulus: f.Modulus}
+	params := s.Params()
+	s.PublicKey = rlwe.NewPublicKey(params.Parameters)
+	s.EvaluationKeys = rlwe.NewEvaluationKeySet()
+	s.PastaKeyCt = bfv.NewCiphertext(params, 1, params.MaxLevel())
+	objs := []interface{ UnmarshalBinary([]byte) error }{s.PublicKey, s.EvaluationKeys, s.PastaKeyCt}
+	for i, obj := range objs {
+		if err := obj.UnmarshalBinary(sections[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return s, nil
+}
+
+// checkPerm fails if path has any of the forbidden permission bits set
+func checkPerm(path string, forbidden os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" { // no unix permissions
+		return nil
+	}
+
+	if info.Mode().Perm()&forbidden != 0 {
+		return fmt.Errorf("%s: insecure permissions %v, expected at most %v", path, info.Mode().Perm(),
+			os.FileMode(0777)&^forbidden)
+	}
+
+	return nil
+}
+
+// joinSections concatenates length prefixed byte sections
+func joinSections(sections ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, s := range sections {
+		_ = binary.Write(&buf, binary.BigEndian, uint64(len(s)))
+		buf.Write(s)
+	}
+
+	return buf.Bytes()
+}
+
+func splitSections(data []byte, n int) ([][]byte, error) {
+	r := bytes.NewReader(data)
+	sections := make([][]byte, n)
+	for i := range sections {
+		var size uint64
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, errors.New("malformed bundle")
+		}
+		if size > uint64(r.Len()) {
+			return nil, errors.New("truncated bundle")
+		}
+		sections[i] = make([]byte, size)
+		if _, err := io.ReadFull(r, sections[i]); err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("trailing bytes in bundle")
+	}
+
+	return sections, nil
+}
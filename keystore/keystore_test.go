@@ -0,0 +1,137 @@
+package keystore
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fedejinich/hhego/util"
+)
+
+func TestKeystore(t *testing.T) {
+	client, server, err := Generate(uint64(math.Pow(2, 14)), 65537, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	t.Run("ClientBundle", func(t *testing.T) {
+		path := filepath.Join(dir, "client.bundle")
+		if err := WriteClientBundle(path, client, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := ReadClientBundle(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.SecretKey.Equal(client.SecretKey) || !util.EqualSlices(c.PastaKey, client.PastaKey) {
+			t.Errorf("client bundle changed on disk")
+		}
+
+		// the pasta key is stored big endian, like every other value list
+		f, err := util.ReadFile(path, util.KindClientBundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sections, err := splitSections(f.Payload, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !util.EqualSlices(util.BytesToUint64Array(sections[1]), client.PastaKey) {
+			t.Errorf("pasta key isn't stored big endian")
+		}
+
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadClientBundle(path, nil); err == nil {
+			t.Errorf("a world readable client bundle should be rejected")
+		}
+	})
+
+	t.Run("SealedClientBundle", func(t *testing.T) {
+		path := filepath.Join(dir, "sealed.bundle")
+		if err := WriteClientBundle(path, client, []byte("correct horse")); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := util.ReadFile(path, util.KindClientBundleSealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skBytes, _ := client.SecretKey.MarshalBinary()
+		if bytes.Contains(f.Payload, skBytes[len(skBytes)-64:]) {
+			t.Errorf("sealed bundle leaks the secret key")
+		}
+
+		if _, err := ReadClientBundle(path, nil); err == nil {
+			t.Errorf("expected an error without passphrase")
+		}
+		if _, err := ReadClientBundle(path, []byte("wrong horse")); err == nil {
+			t.Errorf("expected an error with a wrong passphrase")
+		}
+
+		c, err := ReadClientBundle(path, []byte("correct horse"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.SecretKey.Equal(client.SecretKey) || !util.EqualSlices(c.PastaKey, client.PastaKey) {
+			t.Errorf("sealed client bundle changed on disk")
+		}
+
+		// costly scrypt parameters are rejected before deriving any key
+		costly := f
+		costly.Payload = append([]byte(nil), f.Payload...)
+		costly.Payload[saltSize] = 30
+		expensive := filepath.Join(dir, "expensive.bundle")
+		if err := util.WriteFile(expensive, costly, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadClientBundle(expensive, []byte("correct horse")); err == nil {
+			t.Errorf("expected an error on costly scrypt parameters")
+		}
+
+		// the header is authenticated too
+		f.Modulus = 786433
+		tampered := filepath.Join(dir, "tampered.bundle")
+		if err := util.WriteFile(tampered, f, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadClientBundle(tampered, []byte("correct horse")); err == nil {
+			t.Errorf("expected an error on a tampered header")
+		}
+	})
+
+	t.Run("ServerBundle", func(t *testing.T) {
+		path := filepath.Join(dir, "server.bundle")
+		if err := WriteServerBundle(path, server); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := ReadServerBundle(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.PublicKey.Equal(server.PublicKey) || !s.PastaKeyCt.Equal(server.PastaKeyCt) {
+			t.Errorf("server bundle changed on disk")
+		}
+		if len(s.EvaluationKeys.GaloisKeys) != len(server.EvaluationKeys.GaloisKeys) ||
+			!s.EvaluationKeys.RelinearizationKey.Equal(server.EvaluationKeys.RelinearizationKey) {
+			t.Errorf("server bundle evaluation keys changed on disk")
+		}
+
+		if _, err := ReadClientBundle(path, nil); err == nil {
+			t.Errorf("a server bundle isn't a client bundle")
+		}
+
+		if err := os.Chmod(path, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadServerBundle(path); err == nil {
+			t.Errorf("a world writable server bundle should be rejected")
+		}
+	})
+}
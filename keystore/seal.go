@@ -0,0 +1,105 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt cost parameters for new bundles, they are stored along the sealed
+// payload so they can be raised later without breaking old bundles
+const (
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+
+	// open rejects costlier parameters, a crafted bundle could make it
+	// allocate gigabytes otherwise (scrypt uses 128*r*2^logN bytes)
+	maxScryptLogN = 17
+	maxScryptR    = 8
+	maxScryptP    = 4
+
+	saltSize = 16
+	keySize  = 32 // AES-256
+)
+
+// seal encrypts payload as salt | logN | r | p | nonce | AES-GCM(payload)
+func seal(payload, passphrase, additionalData []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	header := append(salt, scryptLogN, scryptR, scryptP)
+	aead, err := newAEAD(passphrase, salt, scryptLogN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(header, nonce...)
+
+	return aead.Seal(sealed, nonce, payload, additionalData), nil
+}
+
+// open reverses seal, it fails on a wrong passphrase or tampered data
+func open(sealed, passphrase, additionalData []byte) ([]byte, error) {
+	if len(sealed) < saltSize+3 {
+		return nil, errors.New("malformed sealed bundle")
+	}
+	salt := sealed[:saltSize]
+	logN, r, p := sealed[saltSize], sealed[saltSize+1], sealed[saltSize+2]
+	if logN == 0 || logN > maxScryptLogN || r == 0 || r > maxScryptR || p == 0 || p > maxScryptP {
+		return nil, fmt.Errorf("unsupported scrypt parameters (logN=%d, r=%d, p=%d)", logN, r, p)
+	}
+
+	aead, err := newAEAD(passphrase, salt, logN, r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[saltSize+3:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("malformed sealed bundle")
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	payload, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted bundle")
+	}
+
+	return payload, nil
+}
+
+func newAEAD(passphrase, salt []byte, logN, r, p byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<logN, int(r), int(p), keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the sealed payload to its file header
+func additionalData(kind string, degree, modulus uint64) []byte {
+	ad := make([]byte, 16, 16+len(kind))
+	binary.BigEndian.PutUint64(ad, degree)
+	binary.BigEndian.PutUint64(ad[8:], modulus)
+
+	return append(ad, kind...)
+}
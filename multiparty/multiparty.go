@@ -0,0 +1,198 @@
+// Package multiparty splits the bfv secret key among n parties (see lattigo's
+// dbfv and drlwe). The public, relinearization and Galois keys are generated
+// collectively from each party's share, no party ever holds the whole secret
+// key, and decrypting takes the partial decryptions of any t of the n
+// parties, meant to be run on the final tally only.
+//
+// Each protocol has a party side (the *Share methods of Party) and an
+// aggregation side (the methods of Session) that anyone, e.g. the node, can
+// run over the shares the parties publish.
+package multiparty
+
+import (
+	"errors"
+	"fmt"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/dbfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/ring/distribution"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/tuneinsight/lattigo/v4/utils/sampling"
+)
+
+// DecryptionNoise is the standard deviation of the noise flooding each
+// partial decryption, it hides the key share of the party behind it
+const DecryptionNoise = 1 << 30
+
+// Session holds what every party agrees on before running the protocols:
+// the parameters, the seed of the common reference string the protocols
+// sample their public randomness from, the identity of each party (non zero
+// and unique) and how many of them it takes to decrypt
+type Session struct {
+	Params    bfv.Parameters
+	Seed      []byte
+	Parties   []drlwe.ShamirPublicPoint
+	Threshold int
+}
+
+// NewSession checks the parties and the threshold make sense
+func NewSession(params bfv.Parameters, seed []byte, parties []drlwe.ShamirPublicPoint,
+	threshold int) (*Session, error) {
+
+	if len(seed) == 0 {
+		return nil, errors.New("missing common reference string seed")
+	}
+	if threshold < 1 || threshold > len(parties) {
+		return nil, fmt.Errorf("threshold must be between 1 and %d, got %d", len(parties), threshold)
+	}
+	seen := make(map[drlwe.ShamirPublicPoint]bool)
+	for _, id := range parties {
+		if id == 0 || seen[id] {
+			return nil, fmt.Errorf("party ids must be non zero and unique, got %d twice or zero", id)
+		}
+		seen[id] = true
+	}
+
+	return &Session{params, seed, parties, threshold}, nil
+}
+
+// crs returns the common reference string of one protocol run, every party
+// derives the same one from the seed and the label of the run
+func (s *Session) crs(label string) drlwe.CRS {
+	prng, err := sampling.NewKeyedPRNG(append([]byte(label+"/"), s.Seed...))
+	if err != nil {
+		panic(err)
+	}
+
+	return prng
+}
+
+func (s *Session) publicKeyCRP() drlwe.CKGCRP {
+	return dbfv.NewCKGProtocol(s.Params).SampleCRP(s.crs("ckg"))
+}
+
+func (s *Session) relinKeyCRP() drlwe.RKGCRP {
+	return dbfv.NewRKGProtocol(s.Params).SampleCRP(s.crs("rkg"))
+}
+
+func (s *Session) galoisKeyCRP(galEl uint64) drlwe.GKGCRP {
+	return dbfv.NewGKGProtocol(s.Params).SampleCRP(s.crs(fmt.Sprintf("gkg/%d", galEl)))
+}
+
+// PublicKey aggregates the public key shares of every party into the
+// collective public key
+func (s *Session) PublicKey(shares []*drlwe.CKGShare) *rlwe.PublicKey {
+	ckg := dbfv.NewCKGProtocol(s.Params)
+	combined := ckg.AllocateShare()
+	for _, share := range shares {
+		ckg.AggregateShares(share, combined, combined)
+	}
+
+	pk := rlwe.NewPublicKey(s.Params.Parameters)
+	ckg.GenPublicKey(combined, s.publicKeyCRP(), pk)
+
+	return pk
+}
+
+// AggregateRelinKeyShares sums the relinearization key shares of every party
+// for one round, the aggregated first round is the input of the second one
+func (s *Session) AggregateRelinKeyShares(shares []*drlwe.RKGShare) *drlwe.RKGShare {
+	rkg := dbfv.NewRKGProtocol(s.Params)
+	_, combined, _ := rkg.AllocateShare()
+	for _, share := range shares {
+		rkg.AggregateShares(share, combined, combined)
+	}
+
+	return combined
+}
+
+// RelinearizationKey builds the collective relinearization key from the
+// aggregated shares of both rounds
+func (s *Session) RelinearizationKey(round1, round2 *drlwe.RKGShare) *rlwe.RelinearizationKey {
+	rlk := rlwe.NewRelinearizationKey(s.Params.Parameters)
+	dbfv.NewRKGProtocol(s.Params).GenRelinearizationKey(round1, round2, rlk)
+
+	return rlk
+}
+
+// GaloisKey aggregates the shares of every party for galEl into the
+// collective galois key
+func (s *Session) GaloisKey(galEl uint64, shares []*drlwe.GKGShare) *rlwe.GaloisKey {
+	gkg := dbfv.NewGKGProtocol(s.Params)
+	combined := gkg.AllocateShare()
+	combined.GaloisElement = galEl
+	for _, share := range shares {
+		gkg.AggregateShares(share, combined, combined)
+	}
+
+	gk := rlwe.NewGaloisKey(s.Params.Parameters)
+	gkg.GenGaloisKey(combined, s.galoisKeyCRP(galEl), gk)
+
+	return gk
+}
+
+// TranscipherGaloisElements returns the galois elements the parties have to
+// generate keys for so messages of messageLength elements can be
+// transciphered (see hhegobfv.TranscipherGaloisElements)
+func (s *Session) TranscipherGaloisElements(messageLength, pastaSeclevel uint64) []uint64 {
+	return hhegobfv.TranscipherGaloisElements(s.Params.Parameters, messageLength, pastaSeclevel,
+		hhegobfv.TranscipherOptions{})
+}
+
+// EvaluationKeys puts the collective keys together in the set Transcipher
+// and the evaluator take
+func EvaluationKeys(rlk *rlwe.RelinearizationKey, gks []*rlwe.GaloisKey) *rlwe.EvaluationKeySet {
+	evks := rlwe.NewEvaluationKeySet()
+	evks.RelinearizationKey = rlk
+	for _, gk := range gks {
+		evks.GaloisKeys[gk.GaloisElement] = gk
+	}
+
+	return evks
+}
+
+// Decrypt combines the decryption shares of Threshold parties, all generated
+// over ct with the same set of active parties, and returns the plaintext
+// slots of ct
+func (s *Session) Decrypt(ct *rlwe.Ciphertext, shares []*drlwe.CKSShare) ([]uint64, error) {
+	if len(shares) != s.Threshold {
+		return nil, fmt.Errorf("decrypting takes %d shares, got %d", s.Threshold, len(shares))
+	}
+
+	cks := newCKSProtocol(s.Params)
+	combined := cks.AllocateShare(ct.Level())
+	for _, share := range shares {
+		cks.AggregateShares(share, combined, combined)
+	}
+
+	// ct under the zero key is the plaintext plus noise
+	out := bfv.NewCiphertext(s.Params, 1, ct.Level())
+	cks.KeySwitch(ct, combined, out)
+	zero := rlwe.NewSecretKey(s.Params.Parameters)
+
+	return bfv.NewEncoder(s.Params).DecodeUintNew(bfv.NewDecryptor(s.Params, zero).DecryptNew(out)), nil
+}
+
+// Reencrypt combines the re-encryption shares of Threshold parties, all
+// generated over ct for the same recipient and set of active parties, and
+// returns ct encrypted under the recipient public key
+func (s *Session) Reencrypt(ct *rlwe.Ciphertext, shares []*drlwe.PCKSShare) (*rlwe.Ciphertext, error) {
+	if len(shares) != s.Threshold {
+		return nil, fmt.Errorf("re-encrypting takes %d shares, got %d", s.Threshold, len(shares))
+	}
+
+	pcks := hhegobfv.NewPCKSProtocol(s.Params)
+	combined := pcks.AllocateShare(ct.Level())
+	for _, share := range shares {
+		pcks.AggregateShares(share, combined, combined)
+	}
+
+	return hhegobfv.Reencrypt(s.Params, ct, combined), nil
+}
+
+func newCKSProtocol(params bfv.Parameters) *drlwe.CKSProtocol {
+	return dbfv.NewCKSProtocol(params, &distribution.DiscreteGaussian{Sigma: DecryptionNoise,
+		Bound: 6 * DecryptionNoise})
+}
@@ -0,0 +1,128 @@
+package multiparty
+
+import (
+	"testing"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/fedejinich/hhego/util"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestThresholdTally(t *testing.T) {
+	params := hhegobfv.GenerateBfvParams(65537, 1<<14)
+	ids := []drlwe.ShamirPublicPoint{1, 2, 3}
+	if _, err := NewSession(params, []byte("seed"), ids, 4); err == nil {
+		t.Errorf("expected an error for a threshold above the number of parties")
+	}
+	session, err := NewSession(params, []byte("seed"), ids, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parties := make([]*Party, len(ids))
+	for i, id := range ids {
+		if parties[i], err = NewParty(session, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// collective keys
+	var ckg []*drlwe.CKGShare
+	var rkg1, rkg2 []*drlwe.RKGShare
+	for _, p := range parties {
+		ckg = append(ckg, p.PublicKeyShare())
+		rkg1 = append(rkg1, p.RelinKeyShareRoundOne())
+	}
+	round1 := session.AggregateRelinKeyShares(rkg1)
+	for _, p := range parties {
+		share, err := p.RelinKeyShareRoundTwo(round1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rkg2 = append(rkg2, share)
+	}
+	pk := session.PublicKey(ckg)
+	rlk := session.RelinearizationKey(round1, session.AggregateRelinKeyShares(rkg2))
+
+	galEl := params.GaloisElementForColumnRotationBy(1)
+	var gkg []*drlwe.GKGShare
+	for _, p := range parties {
+		gkg = append(gkg, p.GaloisKeyShare(galEl))
+	}
+	evks := EvaluationKeys(rlk, []*rlwe.GaloisKey{session.GaloisKey(galEl, gkg)})
+
+	// threshold setup
+	received := make(map[drlwe.ShamirPublicPoint][]*drlwe.ShamirSecretShare)
+	for _, p := range parties {
+		shares, err := p.ThresholdShares()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, share := range shares {
+			received[id] = append(received[id], share)
+		}
+	}
+	for _, p := range parties {
+		if err := p.ReceiveThresholdShares(received[p.ID]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// (v1 * v2) rotated by one, under the collective key
+	encoder := bfv.NewEncoder(params)
+	encryptor := bfv.NewEncryptor(params, pk)
+	evaluator := bfv.NewEvaluator(params, evks)
+	encrypt := func(values []uint64) *rlwe.Ciphertext {
+		pt := bfv.NewPlaintext(params, params.MaxLevel())
+		encoder.Encode(values, pt)
+		return encryptor.EncryptNew(pt)
+	}
+	ct := evaluator.MulRelinNew(encrypt([]uint64{1, 2, 3, 4}), encrypt([]uint64{5, 6, 7, 8}))
+	evaluator.RotateColumns(ct, 1, ct)
+
+	// any two parties decrypt
+	active := []drlwe.ShamirPublicPoint{3, 1}
+	var cks []*drlwe.CKSShare
+	for _, p := range []*Party{parties[2], parties[0]} {
+		share, err := p.DecryptionShare(ct, active)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cks = append(cks, share)
+	}
+	if _, err := parties[1].DecryptionShare(ct, active); err == nil {
+		t.Errorf("an inactive party shouldn't produce a decryption share")
+	}
+	if _, err := session.Decrypt(ct, cks[:1]); err == nil {
+		t.Errorf("a single party shouldn't be able to decrypt")
+	}
+
+	decrypted, err := session.Decrypt(ct, cks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !util.EqualSlices(decrypted[:3], []uint64{12, 21, 32}) {
+		t.Errorf("decrypted %v", decrypted[:3])
+	}
+
+	// any two parties re-encrypt for a recipient
+	recipientSk, recipientPk := bfv.NewKeyGenerator(params).GenKeyPairNew()
+	var pcks []*drlwe.PCKSShare
+	for _, p := range []*Party{parties[2], parties[0]} {
+		share, err := p.ReencryptionShare(ct, recipientPk, active)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pcks = append(pcks, share)
+	}
+	reencrypted, err := session.Reencrypt(ct, pcks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted = encoder.DecodeUintNew(bfv.NewDecryptor(params, recipientSk).DecryptNew(reencrypted))
+	if !util.EqualSlices(decrypted[:3], []uint64{12, 21, 32}) {
+		t.Errorf("the recipient decrypted %v", decrypted[:3])
+	}
+}
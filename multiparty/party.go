@@ -0,0 +1,190 @@
+package multiparty
+
+import (
+	"errors"
+	"fmt"
+
+	hhegobfv "github.com/fedejinich/hhego/bfv"
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/dbfv"
+	"github.com/tuneinsight/lattigo/v4/drlwe"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Party is one of the key holders of a session. Its secret key is its
+// additive share of the collective key, once the threshold setup is done
+// the party keeps its Shamir share instead, so any Threshold parties can
+// decrypt without the rest.
+type Party struct {
+	ID      drlwe.ShamirPublicPoint
+	session *Session
+
+	sk    *rlwe.SecretKey
+	ephSk *rlwe.SecretKey // between both relinearization key rounds
+
+	shamir         *drlwe.ShamirPolynomial
+	thresholdShare *drlwe.ShamirSecretShare
+}
+
+// NewParty samples the key share of party id
+func NewParty(s *Session, id drlwe.ShamirPublicPoint) (*Party, error) {
+	found := false
+	for _, p := range s.Parties {
+		found = found || p == id
+	}
+	if !found {
+		return nil, fmt.Errorf("party %d isn't part of the session", id)
+	}
+
+	return &Party{ID: id, session: s, sk: bfv.NewKeyGenerator(s.Params).GenSecretKeyNew()}, nil
+}
+
+// PublicKeyShare returns the share of the party for the collective public
+// key
+func (p *Party) PublicKeyShare() *drlwe.CKGShare {
+	ckg := dbfv.NewCKGProtocol(p.session.Params)
+	share := ckg.AllocateShare()
+	ckg.GenShare(p.sk, p.session.publicKeyCRP(), share)
+
+	return share
+}
+
+// RelinKeyShareRoundOne returns the first round share of the party for the
+// collective relinearization key
+func (p *Party) RelinKeyShareRoundOne() *drlwe.RKGShare {
+	rkg := dbfv.NewRKGProtocol(p.session.Params)
+	ephSk, share, _ := rkg.AllocateShare()
+	rkg.GenShareRoundOne(p.sk, p.session.relinKeyCRP(), ephSk, share)
+	p.ephSk = ephSk
+
+	return share
+}
+
+// RelinKeyShareRoundTwo returns the second round share of the party given the
+// aggregated first round (see Session.AggregateRelinKeyShares)
+func (p *Party) RelinKeyShareRoundTwo(round1 *drlwe.RKGShare) (*drlwe.RKGShare, error) {
+	if p.ephSk == nil {
+		return nil, errors.New("the first round must run before the second one")
+	}
+
+	rkg := dbfv.NewRKGProtocol(p.session.Params)
+	_, _, share := rkg.AllocateShare()
+	rkg.GenShareRoundTwo(p.ephSk, p.sk, round1, share)
+	p.ephSk = nil
+
+	return share, nil
+}
+
+// GaloisKeyShare returns the share of the party for the collective galois key
+// of galEl
+func (p *Party) GaloisKeyShare(galEl uint64) *drlwe.GKGShare {
+	gkg := dbfv.NewGKGProtocol(p.session.Params)
+	share := gkg.AllocateShare()
+	gkg.GenShare(p.sk, galEl, p.session.galoisKeyCRP(galEl), share)
+
+	return share
+}
+
+// ThresholdShares splits the key share of the party into one Shamir share
+// for every party of the session (itself included), each must be sent
+// privately to its recipient
+func (p *Party) ThresholdShares() (map[drlwe.ShamirPublicPoint]*drlwe.ShamirSecretShare, error) {
+	thr := drlwe.NewThresholdizer(p.session.Params.Parameters)
+	if p.shamir == nil {
+		shamir, err := thr.GenShamirPolynomial(p.session.Threshold, p.sk)
+		if err != nil {
+			return nil, err
+		}
+		p.shamir = shamir
+	}
+
+	shares := make(map[drlwe.ShamirPublicPoint]*drlwe.ShamirSecretShare, len(p.session.Parties))
+	for _, id := range p.session.Parties {
+		shares[id] = thr.AllocateThresholdSecretShare()
+		thr.GenShamirSecretShare(id, p.shamir, shares[id])
+	}
+
+	return shares, nil
+}
+
+// ReceiveThresholdShares aggregates the Shamir shares every party sent to
+// this one into its threshold share, the additive key share isn't needed
+// anymore so it's dropped
+func (p *Party) ReceiveThresholdShares(shares []*drlwe.ShamirSecretShare) error {
+	if len(shares) != len(p.session.Parties) {
+		return fmt.Errorf("expected a share from each of the %d parties, got %d", len(p.session.Parties),
+			len(shares))
+	}
+
+	thr := drlwe.NewThresholdizer(p.session.Params.Parameters)
+	tsk := thr.AllocateThresholdSecretShare()
+	for _, share := range shares {
+		thr.AggregateShares(tsk, share, tsk)
+	}
+	p.thresholdShare = tsk
+	p.sk, p.shamir = nil, nil
+
+	return nil
+}
+
+// DecryptionShare returns the partial decryption of ct by the party, when
+// the parties in active (this one included, at least Threshold of them)
+// decrypt together. It only works after the threshold setup.
+func (p *Party) DecryptionShare(ct *rlwe.Ciphertext, active []drlwe.ShamirPublicPoint) (*drlwe.CKSShare, error) {
+	additive, err := p.additiveShare(active)
+	if err != nil {
+		return nil, err
+	}
+
+	params := p.session.Params
+	cks := newCKSProtocol(params)
+	share := cks.AllocateShare(ct.Level())
+	cks.GenShare(additive, rlwe.NewSecretKey(params.Parameters), ct, share)
+
+	return share, nil
+}
+
+// ReencryptionShare returns the share of the party for moving ct to the
+// recipient public key (see Session.Reencrypt), when the parties in active
+// (this one included, at least Threshold of them) re-encrypt together. It
+// only works after the threshold setup.
+func (p *Party) ReencryptionShare(ct *rlwe.Ciphertext, recipient *rlwe.PublicKey,
+	active []drlwe.ShamirPublicPoint) (*drlwe.PCKSShare, error) {
+
+	additive, err := p.additiveShare(active)
+	if err != nil {
+		return nil, err
+	}
+
+	pcks := hhegobfv.NewPCKSProtocol(p.session.Params)
+	share := pcks.AllocateShare(ct.Level())
+	pcks.GenShare(additive, recipient, ct, share)
+
+	return share, nil
+}
+
+// additiveShare returns the t-out-of-t additive share of the key of the
+// party among the first Threshold parties of active
+func (p *Party) additiveShare(active []drlwe.ShamirPublicPoint) (*rlwe.SecretKey, error) {
+	if p.thresholdShare == nil {
+		return nil, errors.New("the party has no threshold share yet")
+	}
+	if len(active) < p.session.Threshold {
+		return nil, fmt.Errorf("decrypting takes %d parties, got %d", p.session.Threshold, len(active))
+	}
+	active = active[:p.session.Threshold]
+	isActive := false
+	for _, id := range active {
+		isActive = isActive || id == p.ID
+	}
+	if !isActive {
+		return nil, fmt.Errorf("party %d isn't one of the first %d active parties", p.ID, p.session.Threshold)
+	}
+
+	params := p.session.Params
+	additive := rlwe.NewSecretKey(params.Parameters)
+	drlwe.NewCombiner(params.Parameters, p.ID, p.session.Parties, p.session.Threshold).
+		GenAdditiveShare(active, p.ID, p.thresholdShare, additive)
+
+	return additive, nil
+}
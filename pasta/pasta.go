@@ -41,6 +41,12 @@ func NewPasta(secretKey []uint64, modulus uint64, params Params) Pasta {
 }
 
 func (p *Pasta) Encrypt(plaintext []uint64) []uint64 {
+	return p.EncryptWithNonce(plaintext, Nonce)
+}
+
+// EncryptWithNonce encrypts plaintext with the keystream of nonce, messages
+// under the same key must use different nonces
+func (p *Pasta) EncryptWithNonce(plaintext []uint64, nonce uint64) []uint64 {
 	size := len(plaintext)
 
 	numBlock := int(math.Ceil(float64(size) / float64(p.Params.PlaintextSize)))
@@ -50,7 +56,7 @@ func (p *Pasta) Encrypt(plaintext []uint64) []uint64 {
 	copy(ciphertext, plaintext)
 
 	for b := uint64(0); b < uint64(numBlock); b++ {
-		ks := pastaUtil.Keystream(Nonce, b)
+		ks := pastaUtil.Keystream(nonce, b)
 		for i := int(b * p.Params.PlaintextSize); i < int((b+1)*p.Params.PlaintextSize) && i < size; i++ {
 			ciphertext[i] = (ciphertext[i] + ks[i-int(b*p.Params.PlaintextSize)]) % p.Modulus
 		}
@@ -60,6 +66,11 @@ func (p *Pasta) Encrypt(plaintext []uint64) []uint64 {
 }
 
 func (p *Pasta) Decrypt(ciphertext []uint64) []uint64 {
+	return p.DecryptWithNonce(ciphertext, Nonce)
+}
+
+// DecryptWithNonce decrypts a ciphertext encrypted with EncryptWithNonce
+func (p *Pasta) DecryptWithNonce(ciphertext []uint64, nonce uint64) []uint64 {
 	size := len(ciphertext)
 
 	numBlock := int(math.Ceil(float64(size) / float64(p.Params.CiphertextSize)))
@@ -69,7 +80,7 @@ func (p *Pasta) Decrypt(ciphertext []uint64) []uint64 {
 	copy(plaintext, ciphertext)
 
 	for b := uint64(0); b < uint64(numBlock); b++ {
-		ks := pasta.Keystream(Nonce, b)
+		ks := pasta.Keystream(nonce, b)
 		for i := int(b * p.Params.CiphertextSize); i < int((b+1)*p.Params.CiphertextSize) && i < size; i++ {
 			if ks[i-int(b*p.Params.PlaintextSize)] > plaintext[i] {
 				plaintext[i] += p.Modulus
@@ -716,3 +716,25 @@ func square(output *[]uint64, input []uint64, modulus uint64) {
 		}
 	}
 }
+
+func TestEncryptWithNonce(t *testing.T) {
+	modulus := uint64(65537)
+	secretKey := make([]uint64, SecretKeySize)
+	for i := range secretKey {
+		secretKey[i] = rand.Uint64() % modulus
+	}
+	pasta := NewPasta(secretKey, modulus, TestParams)
+	plaintext := []uint64{1, 2, 3}
+
+	c1 := pasta.EncryptWithNonce(plaintext, 1)
+	c2 := pasta.EncryptWithNonce(plaintext, 2)
+	if util.EqualSlices(c1, c2) {
+		t.Errorf("different nonces gave the same ciphertext")
+	}
+	if !util.EqualSlices(pasta.EncryptWithNonce(plaintext, Nonce), pasta.Encrypt(plaintext)) {
+		t.Errorf("Encrypt should use the default nonce")
+	}
+	if !util.EqualSlices(pasta.DecryptWithNonce(c2, 2), plaintext) {
+		t.Errorf("wrong decryption")
+	}
+}
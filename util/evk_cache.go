@@ -0,0 +1,134 @@
+package util
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvkHash identifies an evaluation key set by the Keccak-256 hash of its
+// serialized bytes
+type EvkHash [32]byte
+
+func HashEvks(data []byte) EvkHash {
+	var h EvkHash
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write(data)
+	keccak.Sum(h[:0])
+
+	return h
+}
+
+// EvkCache keeps the most recently used deserialized evaluation key sets, up
+// to maxBytes of serialized keys, so a key set reused across many calls is
+// only unmarshalled once. It's safe for concurrent use, the key sets it
+// returns must be treated as read only.
+type EvkCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	lru      *list.List // front is the most recently used
+	entries  map[EvkHash]*list.Element
+}
+
+type evkCacheEntry struct {
+	hash EvkHash
+	evks *rlwe.EvaluationKeySet
+	size int64
+}
+
+func NewEvkCache(maxBytes int64) *EvkCache {
+	return &EvkCache{maxBytes: maxBytes, lru: list.New(), entries: make(map[EvkHash]*list.Element)}
+}
+
+// Put returns the hash of the key set serialized in data and the key set,
+// unmarshalling it only if it isn't cached yet. Least recently used key sets
+// are evicted to make room, one larger than the whole cache isn't accepted.
+func (c *EvkCache) Put(data []byte) (EvkHash, *rlwe.EvaluationKeySet, error) {
+	hash := HashEvks(data)
+	if evks, ok := c.Get(hash); ok {
+		return hash, evks, nil
+	}
+
+	size := int64(len(data))
+	if size > c.Limit() {
+		return hash, nil, fmt.Errorf("an evaluation key set of %d bytes doesn't fit in the cache (%d bytes)", size,
+			c.Limit())
+	}
+	evks := rlwe.NewEvaluationKeySet()
+	if err := evks.UnmarshalBinary(data); err != nil {
+		return hash, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// someone else may have added it while unmarshalling
+	if e, ok := c.entries[hash]; ok {
+		c.lru.MoveToFront(e)
+		return hash, e.Value.(*evkCacheEntry).evks, nil
+	}
+	c.entries[hash] = c.lru.PushFront(&evkCacheEntry{hash, evks, size})
+	c.used += size
+	c.evict()
+
+	return hash, evks, nil
+}
+
+// Get returns the cached key set of hash, if any
+func (c *EvkCache) Get(hash EvkHash) (*rlwe.EvaluationKeySet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+
+	return e.Value.(*evkCacheEntry).evks, true
+}
+
+// SetLimit changes the size of the cache, evicting key sets if it shrinks. A
+// negative limit is rejected, 0 disables the cache.
+func (c *EvkCache) SetLimit(maxBytes int64) error {
+	if maxBytes < 0 {
+		return fmt.Errorf("negative evaluation key cache limit %d", maxBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.evict()
+
+	return nil
+}
+
+func (c *EvkCache) Limit() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.maxBytes
+}
+
+// Size returns how many key sets are cached and their serialized size
+func (c *EvkCache) Size() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len(), c.used
+}
+
+func (c *EvkCache) evict() {
+	for c.used > c.maxBytes && c.lru.Len() > 0 {
+		e := c.lru.Back()
+		entry := e.Value.(*evkCacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, entry.hash)
+		c.used -= entry.size
+	}
+}
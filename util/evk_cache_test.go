@@ -0,0 +1,74 @@
+package util
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/bfv"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestEvkCache(t *testing.T) {
+	// Keccak-256, not the NIST SHA3-256
+	empty := HashEvks(nil)
+	if hex.EncodeToString(empty[:]) != "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470" {
+		t.Errorf("wrong keccak256 of the empty input %x", empty)
+	}
+
+	params, _ := bfv.NewParametersFromLiteral(bfv.PN12QP109)
+	kgen := bfv.NewKeyGenerator(params)
+	sk := kgen.GenSecretKeyNew()
+	keySets := make([][]byte, 3)
+	for i := range keySets {
+		evks := rlwe.NewEvaluationKeySet()
+		gk := kgen.GenGaloisKeyNew(params.GaloisElementForColumnRotationBy(i+1), sk)
+		evks.GaloisKeys[gk.GaloisElement] = gk
+		data, err := evks.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keySets[i] = data
+	}
+	size := int64(len(keySets[0]))
+
+	cache := NewEvkCache(2 * size)
+	h0, evks, err := cache.Put(keySets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h0 != HashEvks(keySets[0]) || len(evks.GaloisKeys) != 1 {
+		t.Errorf("wrong hash or key set")
+	}
+	if _, again, _ := cache.Put(keySets[0]); again != evks {
+		t.Errorf("a cached key set was unmarshalled again")
+	}
+
+	// 0 was used last, 1 is evicted to make room for 2
+	h1, _, _ := cache.Put(keySets[1])
+	cache.Get(h0)
+	h2, _, _ := cache.Put(keySets[2])
+	if _, ok := cache.Get(h1); ok {
+		t.Errorf("the least recently used key set wasn't evicted")
+	}
+	if _, ok := cache.Get(h0); !ok {
+		t.Errorf("a recently used key set was evicted")
+	}
+	if n, used := cache.Size(); n != 2 || used != 2*size {
+		t.Errorf("%d key sets of %d bytes cached", n, used)
+	}
+
+	if err := cache.SetLimit(-1); err == nil {
+		t.Errorf("expected an error for a negative limit")
+	}
+	if cache.Limit() != 2*size {
+		t.Errorf("a negative limit changed the cache limit to %d", cache.Limit())
+	}
+	cache.SetLimit(size)
+	if _, ok := cache.Get(h2); ok {
+		t.Errorf("shrinking the cache didn't evict the least recently used key set")
+	}
+	cache.SetLimit(size - 1)
+	if _, _, err := cache.Put(keySets[1]); err == nil {
+		t.Errorf("expected an error for a key set larger than the cache")
+	}
+}
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileVersion is the current version of the hhego file format
+const FileVersion = uint16(1)
+
+var fileMagic = [4]byte{'H', 'H', 'E', 'G'}
+
+// File kinds written by the hhego tools
+const (
+	KindBfvSecretKey         = "bfv-sk"
+	KindBfvPublicKey         = "bfv-pk"
+	KindBfvRelinKey          = "bfv-rlk"
+	KindBfvEvaluationKeys    = "bfv-evk"
+	KindBfvCiphertext        = "bfv-ct"
+	KindBfvPackedCiphertexts = "bfv-ct-packed"
+	KindPastaSecretKey       = "pasta-sk"
+	KindPastaSecretKeyCt     = "pasta-sk-ct"
+	KindPastaCiphertext      = "pasta-ct"
+	KindPlaintext            = "plain"
+
+	KindClientBundle       = "client-bundle"
+	KindClientBundleSealed = "client-bundle-sealed"
+	KindServerBundle       = "server-bundle"
+)
+
+// File is the versioned envelope used to store keys, ciphertexts and plain
+// values on disk. Degree and Modulus identify the BFV parameters the payload
+// belongs to.
+type File struct {
+	Version uint16
+	Kind    string
+	Degree  uint64
+	Modulus uint64
+	Payload []byte
+}
+
+// NewFile creates a File with the current version
+func NewFile(kind string, degree, modulus uint64, payload []byte) File {
+	return File{FileVersion, kind, degree, modulus, payload}
+}
+
+// MarshalBinary encodes f as
+// magic | version | len(kind) | kind | degree | modulus | len(payload) | payload
+func (f *File) MarshalBinary() ([]byte, error) {
+	if len(f.Kind) > 0xffff {
+		return nil, errors.New("file kind too long")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(fileMagic[:])
+	fields := []interface{}{f.Version, uint16(len(f.Kind)), []byte(f.Kind), f.Degree, f.Modulus,
+		uint64(len(f.Payload)), f.Payload}
+	for _, v := range fields {
+		if err := binary.Write(&buf, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (f *File) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || magic != fileMagic {
+		return errors.New("not an hhego file")
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &f.Version); err != nil {
+		return err
+	}
+	if f.Version != FileVersion {
+		return fmt.Errorf("unsupported file version %d", f.Version)
+	}
+
+	var kindLen uint16
+	if err := binary.Read(r, binary.BigEndian, &kindLen); err != nil {
+		return err
+	}
+	kind := make([]byte, kindLen)
+	if _, err := io.ReadFull(r, kind); err != nil {
+		return err
+	}
+	f.Kind = string(kind)
+
+	var payloadLen uint64
+	for _, v := range []interface{}{&f.Degree, &f.Modulus, &payloadLen} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	if payloadLen != uint64(r.Len()) {
+		return errors.New("truncated or oversized payload")
+	}
+	f.Payload = make([]byte, payloadLen)
+	_, err := io.ReadFull(r, f.Payload)
+
+	return err
+}
+
+// WriteFile writes f to path with the given permissions, also if it already
+// exists with other ones
+func WriteFile(path string, f File, perm os.FileMode) error {
+	data, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+
+	// os.WriteFile keeps the mode of existing files, enforce it
+	return os.Chmod(path, perm)
+}
+
+// ReadFile reads a File from path and checks it holds the expected kind
+func ReadFile(path, kind string) (File, error) {
+	var f File
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	if err := f.UnmarshalBinary(data); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if kind != "" && f.Kind != kind {
+		return f, fmt.Errorf("%s: expected a %s file, got %s", path, kind, f.Kind)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,11 @@
+package util
+
+import (
+	"io"
+	"os"
+)
+
+// Progress receives the progress messages of long running operations
+// (transciphering, parameter generation, evaluation), os.Stdout by default.
+// Point it to another writer, or io.Discard, to keep them out of stdout.
+var Progress io.Writer = os.Stdout
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Registry hands out opaque int64 handles to Go objects so callers across the
+// JNI boundary (which can't hold Go pointers) can load them once and refer to
+// them in later calls. It's safe for concurrent use.
+//
+// Registering takes the caller's reference and Free drops it, each call using
+// the object takes its own with Acquire and drops it with Release, so an
+// object freed while calls are still running is only dropped once they're
+// done.
+type Registry struct {
+	mu      sync.Mutex
+	next    int64
+	entries map[int64]*registryEntry
+}
+
+type registryEntry struct {
+	value interface{}
+	refs  int
+	freed bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[int64]*registryEntry)}
+}
+
+// Register stores value and returns its handle, never 0 so it can stand for
+// a missing handle
+func (r *Registry) Register(value interface{}) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next++
+	r.entries[r.next] = &registryEntry{value: value, refs: 1}
+
+	return r.next
+}
+
+// Acquire returns the object of handle, it stays alive until the matching
+// Release even if it's freed meanwhile
+func (r *Registry) Acquire(handle int64) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[handle]
+	if !ok || e.freed {
+		return nil, fmt.Errorf("unknown handle %d", handle)
+	}
+	e.refs++
+
+	return e.value, nil
+}
+
+// Release drops a reference taken with Acquire
+func (r *Registry) Release(handle int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unref(handle)
+}
+
+// Free drops the reference of the caller who registered handle, the handle
+// can't be acquired anymore
+func (r *Registry) Free(handle int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[handle]
+	if !ok || e.freed {
+		return fmt.Errorf("unknown handle %d", handle)
+	}
+	e.freed = true
+	r.unref(handle)
+
+	return nil
+}
+
+// Len returns how many objects are alive, freed ones still in use included
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.entries)
+}
+
+func (r *Registry) unref(handle int64) {
+	e, ok := r.entries[handle]
+	if !ok {
+		return
+	}
+	e.refs--
+	if e.refs == 0 {
+		delete(r.entries, handle)
+	}
+}
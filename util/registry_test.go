@@ -0,0 +1,59 @@
+package util
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	h := r.Register("keys")
+	if h == 0 {
+		t.Fatalf("handle 0 is reserved")
+	}
+
+	v, err := r.Acquire(h)
+	if err != nil || v != "keys" {
+		t.Fatalf("acquired %v, %v", v, err)
+	}
+
+	// freed while in use: no new users, alive until released
+	if err := r.Free(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Acquire(h); err == nil {
+		t.Errorf("a freed handle shouldn't be acquired")
+	}
+	if err := r.Free(h); err == nil {
+		t.Errorf("expected an error freeing twice")
+	}
+	if r.Len() != 1 {
+		t.Errorf("the object was dropped while in use")
+	}
+	r.Release(h)
+	if r.Len() != 0 {
+		t.Errorf("the object wasn't dropped after its last release")
+	}
+
+	// concurrent users
+	h = r.Register("evks")
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Acquire(h); err != nil {
+				t.Error(err)
+				return
+			}
+			r.Release(h)
+		}()
+	}
+	wg.Wait()
+	if err := r.Free(h); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d objects left", r.Len())
+	}
+}
@@ -33,16 +33,16 @@ func ExecuteOp(evaluator bfv.Evaluator, ct1 *rlwe.Ciphertext, ct2 *rlwe.Cipherte
 	var result *rlwe.Ciphertext
 	switch caseType {
 	case Add:
-		fmt.Println("ExecuteOpAdd")
+		fmt.Fprintln(Progress, "ExecuteOpAdd")
 		result = evaluator.AddNew(ct1, ct2)
 		break
 	case Sub:
-		fmt.Println("ExecuteOpSub")
+		fmt.Fprintln(Progress, "ExecuteOpSub")
 		result = evaluator.SubNew(ct1, ct2)
 		break
 	case Mul:
 		{
-			fmt.Println("ExecuteOpMul")
+			fmt.Fprintln(Progress, "ExecuteOpMul")
 			result = evaluator.MulRelinNew(ct1, ct2)
 			break
 		}
@@ -59,7 +59,7 @@ func NoiseBudget(evaluator bfv.Evaluator, decryptor rlwe.Decryptor, encoder bfv.
 
 	// resCt0, _, _ := rlwe.Norm(ct0, decryptor)
 	// resCt1, _, _ := rlwe.Norm(ct1, decryptor)
-	fmt.Printf("STD(noise)res: %d\n", int(res))
+	fmt.Fprintf(Progress, "STD(noise)res: %d\n", int(res))
 	// fmt.Printf("STD(noise)ct0: %d\n", int(resCt0))
 	// fmt.Printf("STD(noise)ct1: %d\n", int(resCt1))
 	//